@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	influxlog "github.com/influxdata/influxdb-client-go/v2/log"
+	"github.com/rs/zerolog"
+	"github.com/stnokott/r6prom/config"
+	"github.com/stnokott/r6prom/constants"
+	"github.com/stnokott/r6prom/dashboard"
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/migrate"
+)
+
+// runCommand runs the subcommand given as first argument, ok is false if args contain no known subcommand.
+func runCommand(args []string, logger *zerolog.Logger) (ok bool, err error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "schema":
+		return true, runSchema(args[1:])
+	case "migrate":
+		return true, runMigrate(args[1:], logger)
+	case "dashboard":
+		return true, runDashboard(args[1:])
+	default:
+		return false, nil
+	}
+}
+
+// runSchema prints the current schema as JSON, using the names as written with the configured rewrites.
+func runSchema(args []string) error {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	conf, err := config.LoadOutput()
+	if err != nil {
+		return err
+	}
+	rewriter := metrics.NewRewriter(conf.MeasurementPrefix, conf.MeasurementRenames, conf.TagRenames, conf.StaticTags)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rewriter.ApplySchema(metrics.CurrentSchema()))
+}
+
+// runMigrate migrates the configured InfluxDB bucket to the current schema version.
+func runMigrate(args []string, logger *zerolog.Logger) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := flags.Int("from", 0, "schema version of the existing data, detected from the bucket if 0")
+	dryRun := flags.Bool("dry-run", false, "print the queries instead of running them")
+	previousPrefix := flags.String("previous-prefix", "", "MEASUREMENT_PREFIX the existing data was written with")
+	previousRenames := flags.String("previous-renames", "", "MEASUREMENT_RENAMES the existing data was written with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	conf, err := config.LoadOutput()
+	if err != nil {
+		return err
+	}
+	if conf.InfluxURL == "" {
+		return errors.New("migration requires INFLUX_URL")
+	}
+	// data is only renamed if the previous names are given, as an empty prefix is a valid previous prefix
+	renamed := false
+	flags.Visit(func(f *flag.Flag) {
+		renamed = renamed || f.Name == "previous-prefix" || f.Name == "previous-renames"
+	})
+	var previous *metrics.Rewriter
+	if renamed {
+		renames, err := config.ParsePairs(*previousRenames)
+		if err != nil {
+			return fmt.Errorf("invalid -previous-renames: %w", err)
+		}
+		previous = metrics.NewRewriter(*previousPrefix, renames, nil, nil)
+	}
+
+	client := influxdb2.NewClientWithOptions(
+		conf.InfluxURL,
+		conf.InfluxAuthToken,
+		influxdb2.DefaultOptions().
+			SetApplicationName(constants.NAME).
+			SetLogLevel(influxlog.ErrorLevel),
+	)
+	defer client.Close()
+
+	migrator := migrate.New(client, migrate.Target{
+		Org:      conf.InfluxOrg,
+		Bucket:   conf.InfluxBucket,
+		Rewriter: metrics.NewRewriter(conf.MeasurementPrefix, conf.MeasurementRenames, conf.TagRenames, conf.StaticTags),
+	}, logger)
+
+	ctx := context.Background()
+	if previous != nil {
+		if err := migrator.Rename(ctx, previous, *dryRun, os.Stdout); err != nil {
+			return err
+		}
+	}
+	version := *from
+	if version == 0 {
+		detector := migrator
+		if previous != nil && *dryRun {
+			// the data still has its previous names
+			detector = migrate.New(client, migrate.Target{Org: conf.InfluxOrg, Bucket: conf.InfluxBucket, Rewriter: previous}, logger)
+		}
+		if version, err = detector.Version(ctx); err != nil {
+			return fmt.Errorf("could not detect schema version: %w", err)
+		}
+	}
+	if version > metrics.SchemaVersion {
+		return fmt.Errorf("bucket has schema version %d, newer than supported version %d", version, metrics.SchemaVersion)
+	}
+	if len(conf.TagRenames) > 0 {
+		logger.Warn().Msg("TAG_RENAMES are not migrated, data written with other tag keys keeps them")
+	}
+	logger.Info().Int("from", version).Int("to", metrics.SchemaVersion).Str("bucket", conf.InfluxBucket).Msg("migrating bucket")
+	return migrator.Run(ctx, version, *dryRun, os.Stdout)
+}
+
+// runDashboard prints a Grafana dashboard for the written measurements, using the names as written with the configured rewrites.
+func runDashboard(args []string) error {
+	conf, err := config.LoadOutput()
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	format := flags.String("format", dashboard.FormatFlux, fmt.Sprintf("query language, %s or %s", dashboard.FormatFlux, dashboard.FormatPrometheus))
+	title := flags.String("title", constants.NAME, "dashboard title")
+	bucket := flags.String("bucket", conf.InfluxBucket, "InfluxDB bucket queried by the flux dashboard")
+	output := flags.String("o", "", "file to write the dashboard to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format == dashboard.FormatFlux && *bucket == "" {
+		return errors.New("flux dashboard requires -bucket or INFLUX_BUCKET")
+	}
+
+	d, err := dashboard.Generate(dashboard.Opts{
+		Format:    *format,
+		Title:     *title,
+		Bucket:    *bucket,
+		Namespace: conf.RemoteWriteNamespace,
+		Rewriter:  metrics.NewRewriter(conf.MeasurementPrefix, conf.MeasurementRenames, conf.TagRenames, conf.StaticTags),
+	})
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if *output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(*output, b, 0o644)
+}
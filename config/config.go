@@ -2,8 +2,15 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/sink"
 )
 
 type Config struct {
@@ -15,6 +22,112 @@ type Config struct {
 	InfluxAuthToken   string
 	InfluxOrg         string
 	InfluxBucket      string
+	// SQLitePath is the path of the SQLite database points are written to, disabled if empty
+	SQLitePath string
+	// LineProtocolURL is the URL of a server with the InfluxDB 1.x compatible write API, disabled if empty
+	LineProtocolURL string
+	// LineProtocolDatabase and LineProtocolRetentionPolicy are the InfluxDB 1.x database and retention policy
+	LineProtocolDatabase        string
+	LineProtocolRetentionPolicy string
+	// LineProtocolUsername and LineProtocolPassword are used for authentication if set
+	LineProtocolUsername string
+	LineProtocolPassword string
+	// RemoteWriteURL is the Prometheus remote-write endpoint points are pushed to, disabled if empty
+	RemoteWriteURL string
+	// RemoteWriteNamespace is prepended to remote-write metric names
+	RemoteWriteNamespace string
+	// RemoteWriteUsername and RemoteWritePassword are used for basic auth if set
+	RemoteWriteUsername string
+	RemoteWritePassword string
+	// RemoteWriteBearerToken is used for bearer token auth if set
+	RemoteWriteBearerToken string
+	// OTLPEndpoint is the OTLP/HTTP endpoint points are exported to, disabled if empty
+	OTLPEndpoint string
+	// OTLPHeaders are sent with every OTLP request
+	OTLPHeaders map[string]string
+	// MQTTURL is the MQTT broker address like "mqtt://host:1883" or "mqtts://host:8883", disabled if empty
+	MQTTURL string
+	// MQTTClientID is the client identifier sent to the broker
+	MQTTClientID string
+	// MQTTUsername and MQTTPassword are used to authenticate at the broker if set
+	MQTTUsername string
+	MQTTPassword string
+	// MQTTCAFile is a PEM file with CA certificates to verify the broker with
+	MQTTCAFile string
+	// MQTTInsecure disables verification of the broker certificate
+	MQTTInsecure bool
+	// MQTTTopicPrefix is the first level of all published topics
+	MQTTTopicPrefix string
+	// MQTTFields lists the "measurement.field" entries published per user
+	MQTTFields []string
+	// MQTTEvents lists the measurements published as events
+	MQTTEvents []string
+	// MQTTRankField is the "measurement.field" whose changes are published as rank change events, disabled if empty
+	MQTTRankField string
+	// MQTTDiscoveryPrefix is the Home Assistant discovery prefix, disabled if empty
+	MQTTDiscoveryPrefix string
+	// FileSinkDir is the directory points are exported to as files, disabled if empty
+	FileSinkDir string
+	// FileSinkFormat is the format of exported files, either jsonl or csv
+	FileSinkFormat string
+	// FileSinkMaxSizeMB rotates exported files once they exceed this size, 0 disables size based rotation
+	FileSinkMaxSizeMB int
+	// FileSinkRotateDaily starts new export files every day
+	FileSinkRotateDaily bool
+	// FileSinkGzip compresses rotated export files
+	FileSinkGzip bool
+	// DryRun prints points in the given format ("lp" or "json") instead of writing them and saves no state, disabled if empty
+	DryRun string
+	// Normalization maps map, operator and bombsite names to canonical names.
+	// Disabled by default, since it changes names of series written before.
+	Normalization bool
+	// NormalizeAliasesFile overrides the bundled alias table, empty to use bundled data
+	NormalizeAliasesFile string
+	// Enrichment adds static metadata like operator sides to points, disabled by default
+	Enrichment bool
+	// EnrichOperatorsFile and EnrichMapsFile override the bundled enrichment data, empty to use bundled data
+	EnrichOperatorsFile string
+	EnrichMapsFile      string
+	// MeasurementPrefix is prepended to all measurement names
+	MeasurementPrefix string
+	// MeasurementRenames maps measurement names to the names they are written as
+	MeasurementRenames map[string]string
+	// TagRenames maps tag keys to the keys they are written as
+	TagRenames map[string]string
+	// StaticTags are added to every written point
+	StaticTags map[string]string
+	// FieldsAllow limits written fields to the listed names, all fields are written if empty
+	FieldsAllow []string
+	// FieldsDeny lists fields that should never be written
+	FieldsDeny []string
+	// UserGroups maps group names to observed usernames for which aggregated stats are written
+	UserGroups map[string][]string
+	// LeaderboardMinRounds is the minimum number of rounds for a user to appear in a leaderboard category
+	LeaderboardMinRounds int
+	// TabStatsBaseURL is the base URL of the tabstats profile API
+	TabStatsBaseURL string
+	// TabStatsTimeout limits the duration of tabstats requests
+	TabStatsTimeout time.Duration
+	// RankedHistoryDepth is the number of ranked seasons requested per user
+	RankedHistoryDepth int
+	// RankedProviders lists the ranked data sources in order of preference
+	RankedProviders []string
+	// RankedDiscrepancyThreshold is the MMR difference above which ranked sources are considered to disagree
+	RankedDiscrepancyThreshold int
+	// StateDir is the directory where state like sessions is persisted
+	StateDir string
+	// SessionIdleRuns is the number of runs without new matches after which a session ends
+	SessionIdleRuns int
+	// StreakEventLength is the streak length from which streak events are written, 0 disables them
+	StreakEventLength int
+	// SnapshotDB is the path of the snapshot database, empty if disabled
+	SnapshotDB string
+	// SnapshotRetention is the duration after which unchanged snapshots are removed, 0 keeps them forever
+	SnapshotRetention time.Duration
+	// HTTPAddr is the address the web server listens on, disabled if empty
+	HTTPAddr string
+	// WebUI serves player overview pages on HTTPAddr
+	WebUI bool
 }
 
 const (
@@ -26,6 +139,75 @@ const (
 	envInfluxAuthToken   string = "INFLUX_AUTH_TOKEN"
 	envInfluxOrg         string = "INFLUX_ORGANIZATION"
 	envInfluxBucket      string = "INFLUX_BUCKET"
+	envSQLitePath        string = "SQLITE_PATH"
+	envLineProtocolURL   string = "LINE_PROTOCOL_URL"
+	envLineProtocolDB    string = "LINE_PROTOCOL_DATABASE"
+	envLineProtocolRP    string = "LINE_PROTOCOL_RETENTION_POLICY"
+	envLineProtocolUser  string = "LINE_PROTOCOL_USERNAME"
+	envLineProtocolPass  string = "LINE_PROTOCOL_PASSWORD"
+	envRemoteWriteURL    string = "REMOTE_WRITE_URL"
+	envRemoteWriteNS     string = "REMOTE_WRITE_NAMESPACE"
+	envRemoteWriteUser   string = "REMOTE_WRITE_USERNAME"
+	envRemoteWritePass   string = "REMOTE_WRITE_PASSWORD"
+	envRemoteWriteToken  string = "REMOTE_WRITE_BEARER_TOKEN"
+	envOTLPEndpoint      string = "OTLP_ENDPOINT"
+	envOTLPHeaders       string = "OTLP_HEADERS"
+	envMQTTURL           string = "MQTT_URL"
+	envMQTTClientID      string = "MQTT_CLIENT_ID"
+	envMQTTUsername      string = "MQTT_USERNAME"
+	envMQTTPassword      string = "MQTT_PASSWORD"
+	envMQTTCAFile        string = "MQTT_TLS_CA_FILE"
+	envMQTTInsecure      string = "MQTT_TLS_INSECURE"
+	envMQTTTopicPrefix   string = "MQTT_TOPIC_PREFIX"
+	envMQTTFields        string = "MQTT_FIELDS"
+	envMQTTEvents        string = "MQTT_EVENTS"
+	envMQTTRankField     string = "MQTT_RANK_FIELD"
+	envMQTTDiscovery     string = "MQTT_DISCOVERY_PREFIX"
+	envFileSinkDir       string = "FILE_SINK_DIR"
+	envFileSinkFormat    string = "FILE_SINK_FORMAT"
+	envFileSinkMaxSize   string = "FILE_SINK_MAX_SIZE_MB"
+	envFileSinkDaily     string = "FILE_SINK_ROTATE_DAILY"
+	envFileSinkGzip      string = "FILE_SINK_GZIP"
+	envDryRun            string = "DRY_RUN"
+	envNormalization     string = "NORMALIZATION"
+	envNormalizeAliases  string = "NORMALIZE_ALIASES_FILE"
+	envEnrichment        string = "ENRICHMENT"
+	envEnrichOperators   string = "ENRICH_OPERATORS_FILE"
+	envEnrichMaps        string = "ENRICH_MAPS_FILE"
+	envMeasurementPrefix string = "MEASUREMENT_PREFIX"
+	envMeasurementRename string = "MEASUREMENT_RENAMES"
+	envTagRenames        string = "TAG_RENAMES"
+	envStaticTags        string = "STATIC_TAGS"
+	envFieldsAllow       string = "FIELDS_ALLOW"
+	envFieldsDeny        string = "FIELDS_DENY"
+	envUserGroups        string = "UBI_USER_GROUPS"
+	envLeaderboardRounds string = "LEADERBOARD_MIN_ROUNDS"
+	envHTTPAddr          string = "HTTP_ADDR"
+	envWebUI             string = "WEB_UI"
+	envTabStatsBaseURL   string = "TABSTATS_BASE_URL"
+	envTabStatsTimeout   string = "TABSTATS_TIMEOUT"
+	envRankedDepth       string = "RANKED_HISTORY_DEPTH"
+	envRankedProviders   string = "RANKED_PROVIDERS"
+	envRankedThreshold   string = "RANKED_DISCREPANCY_THRESHOLD"
+	envStateDir          string = "STATE_DIR"
+	envSessionIdleRuns   string = "SESSION_IDLE_RUNS"
+	envStreakEventLength string = "STREAK_EVENT_LENGTH"
+	envSnapshotDB        string = "SNAPSHOT_DB"
+	envSnapshotRetention string = "SNAPSHOT_RETENTION"
+)
+
+const (
+	defaultLeaderboardMinRounds = 10
+	defaultStateDir             = "state"
+	defaultSessionIdleRuns      = 3
+	defaultStreakEventLength    = 5
+	defaultSnapshotFile         = "snapshots.db"
+	defaultSnapshotRetention    = 90 * 24 * time.Hour
+	defaultFileSinkFormat       = sink.FormatJSONLines
+	defaultTabStatsBaseURL      = "https://r6.apitab.net/website/profiles/"
+	defaultTabStatsTimeout      = 15 * time.Second
+	// disabled can be set as SNAPSHOT_DB, MQTT_RANK_FIELD or MQTT_DISCOVERY_PREFIX to disable the feature
+	disabled = "off"
 )
 
 var requiredEnvs = []string{
@@ -33,7 +215,10 @@ var requiredEnvs = []string{
 	envPassword,
 	envObservedUsernames,
 	envRefreshCron,
-	envInfluxURL,
+}
+
+// influxEnvs are required if InfluxDB is used, which is the case if INFLUX_URL is set.
+var influxEnvs = []string{
 	envInfluxAuthToken,
 	envInfluxOrg,
 	envInfluxBucket,
@@ -59,10 +244,350 @@ func Load() (c Config, err error) {
 		return
 	}
 	c.RefreshCron = vals[envRefreshCron]
-	c.InfluxURL = vals[envInfluxURL]
-	c.InfluxAuthToken = vals[envInfluxAuthToken]
-	c.InfluxOrg = vals[envInfluxOrg]
-	c.InfluxBucket = vals[envInfluxBucket]
 
+	if err = loadInflux(&c); err != nil {
+		return
+	}
+	c.SQLitePath = os.Getenv(envSQLitePath)
+	c.LineProtocolURL = os.Getenv(envLineProtocolURL)
+	c.LineProtocolDatabase = os.Getenv(envLineProtocolDB)
+	c.LineProtocolRetentionPolicy = os.Getenv(envLineProtocolRP)
+	c.LineProtocolUsername = os.Getenv(envLineProtocolUser)
+	c.LineProtocolPassword = os.Getenv(envLineProtocolPass)
+	c.RemoteWriteURL = os.Getenv(envRemoteWriteURL)
+	c.RemoteWriteUsername = os.Getenv(envRemoteWriteUser)
+	c.RemoteWritePassword = os.Getenv(envRemoteWritePass)
+	c.RemoteWriteBearerToken = os.Getenv(envRemoteWriteToken)
+	c.OTLPEndpoint = os.Getenv(envOTLPEndpoint)
+	c.OTLPHeaders, err = parseHeaders(os.Getenv(envOTLPHeaders))
+	if err != nil {
+		err = fmt.Errorf("environment variable %s: %w", envOTLPHeaders, err)
+		return
+	}
+	c.MQTTURL = os.Getenv(envMQTTURL)
+	c.MQTTClientID = stringOptional(envMQTTClientID, sink.DefaultMQTTClientID)
+	c.MQTTUsername = os.Getenv(envMQTTUsername)
+	c.MQTTPassword = os.Getenv(envMQTTPassword)
+	c.MQTTCAFile = os.Getenv(envMQTTCAFile)
+	c.MQTTInsecure, err = boolOptional(envMQTTInsecure, false)
+	if err != nil {
+		return
+	}
+	c.MQTTTopicPrefix = stringOptional(envMQTTTopicPrefix, sink.DefaultMQTTTopicPrefix)
+	c.MQTTFields = splitOptional(envMQTTFields)
+	if len(c.MQTTFields) == 0 {
+		c.MQTTFields = sink.DefaultMQTTFields
+	}
+	c.MQTTEvents = splitOptional(envMQTTEvents)
+	if len(c.MQTTEvents) == 0 {
+		c.MQTTEvents = sink.DefaultMQTTEvents
+	}
+	c.MQTTRankField = stringOptional(envMQTTRankField, sink.DefaultMQTTRankField)
+	if c.MQTTRankField == disabled {
+		c.MQTTRankField = ""
+	}
+	c.MQTTDiscoveryPrefix = stringOptional(envMQTTDiscovery, sink.DefaultMQTTDiscoveryPrefix)
+	if c.MQTTDiscoveryPrefix == disabled {
+		c.MQTTDiscoveryPrefix = ""
+	}
+	c.FileSinkDir = os.Getenv(envFileSinkDir)
+	c.FileSinkFormat = strings.ToLower(strings.TrimSpace(os.Getenv(envFileSinkFormat)))
+	if c.FileSinkFormat == "" {
+		c.FileSinkFormat = defaultFileSinkFormat
+	}
+	c.FileSinkMaxSizeMB, err = intOptional(envFileSinkMaxSize, 0)
+	if err != nil {
+		return
+	}
+	c.FileSinkRotateDaily, err = boolOptional(envFileSinkDaily, true)
+	if err != nil {
+		return
+	}
+	c.FileSinkGzip, err = boolOptional(envFileSinkGzip, false)
+	if err != nil {
+		return
+	}
+
+	c.DryRun = strings.ToLower(strings.TrimSpace(os.Getenv(envDryRun)))
+	switch c.DryRun {
+	case "", sink.FormatLineProtocol, sink.FormatJSON:
+	case "true", "1", "line":
+		c.DryRun = sink.FormatLineProtocol
+	case "false", "0":
+		c.DryRun = ""
+	default:
+		err = fmt.Errorf("environment variable %s needs to be one of lp, json or false", envDryRun)
+		return
+	}
+
+	c.Normalization, err = boolOptional(envNormalization, false)
+	if err != nil {
+		return
+	}
+	c.NormalizeAliasesFile = os.Getenv(envNormalizeAliases)
+	c.Enrichment, err = boolOptional(envEnrichment, false)
+	if err != nil {
+		return
+	}
+	c.EnrichOperatorsFile = os.Getenv(envEnrichOperators)
+	c.EnrichMapsFile = os.Getenv(envEnrichMaps)
+
+	if err = loadRewrite(&c); err != nil {
+		return
+	}
+
+	c.FieldsAllow = splitOptional(envFieldsAllow)
+	c.FieldsDeny = splitOptional(envFieldsDeny)
+
+	c.UserGroups, err = parseUserGroups(os.Getenv(envUserGroups), c.ObservedUsernames)
+	if err != nil {
+		return
+	}
+	c.LeaderboardMinRounds, err = intOptional(envLeaderboardRounds, defaultLeaderboardMinRounds)
+	if err != nil {
+		return
+	}
+	c.HTTPAddr = os.Getenv(envHTTPAddr)
+	c.WebUI, err = boolOptional(envWebUI, false)
+	if err != nil {
+		return
+	}
+	c.StateDir = os.Getenv(envStateDir)
+	if c.StateDir == "" {
+		c.StateDir = defaultStateDir
+	}
+	c.SessionIdleRuns, err = intOptional(envSessionIdleRuns, defaultSessionIdleRuns)
+	if err != nil {
+		return
+	}
+	c.StreakEventLength, err = intOptional(envStreakEventLength, defaultStreakEventLength)
+	if err != nil {
+		return
+	}
+	c.SnapshotDB = os.Getenv(envSnapshotDB)
+	switch c.SnapshotDB {
+	case "":
+		c.SnapshotDB = filepath.Join(c.StateDir, defaultSnapshotFile)
+	case disabled:
+		c.SnapshotDB = ""
+	}
+	c.SnapshotRetention, err = durationOptional(envSnapshotRetention, defaultSnapshotRetention)
+	if err != nil {
+		return
+	}
+	if c.WebUI && c.HTTPAddr == "" {
+		err = fmt.Errorf("environment variable %s requires %s to be set", envWebUI, envHTTPAddr)
+		return
+	}
+	if c.WebUI && c.SnapshotDB == "" {
+		err = fmt.Errorf("environment variable %s requires snapshots, but %s is %s", envWebUI, envSnapshotDB, disabled)
+		return
+	}
+	c.TabStatsBaseURL = os.Getenv(envTabStatsBaseURL)
+	if c.TabStatsBaseURL == "" {
+		c.TabStatsBaseURL = defaultTabStatsBaseURL
+	}
+	c.TabStatsTimeout, err = durationOptional(envTabStatsTimeout, defaultTabStatsTimeout)
+	if err != nil {
+		return
+	}
+	c.RankedHistoryDepth, err = intOptional(envRankedDepth, metrics.DefaultRankedHistoryDepth)
+	if err != nil {
+		return
+	}
+	if c.RankedHistoryDepth < 1 {
+		err = fmt.Errorf("environment variable %s needs to be at least 1", envRankedDepth)
+		return
+	}
+	c.RankedProviders = splitOptional(envRankedProviders)
+	if len(c.RankedProviders) == 0 {
+		c.RankedProviders = metrics.DefaultRankedProviders
+	}
+	c.RankedDiscrepancyThreshold, err = intOptional(envRankedThreshold, metrics.DefaultRankedDiscrepancyThreshold)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// LoadOutput loads only the InfluxDB settings and the names of written data, as needed by commands which don't collect stats.
+func LoadOutput() (c Config, err error) {
+	if err = loadInflux(&c); err != nil {
+		return
+	}
+	err = loadRewrite(&c)
 	return
 }
+
+func loadInflux(c *Config) error {
+	c.InfluxURL = os.Getenv(envInfluxURL)
+	// also read without URL, e.g. to generate dashboards for the bucket
+	c.InfluxAuthToken = os.Getenv(envInfluxAuthToken)
+	c.InfluxOrg = os.Getenv(envInfluxOrg)
+	c.InfluxBucket = os.Getenv(envInfluxBucket)
+	if c.InfluxURL == "" {
+		return nil
+	}
+	for _, envKey := range influxEnvs {
+		if _, exists := os.LookupEnv(envKey); !exists {
+			return fmt.Errorf("environment variable %s missing, required if %s is set", envKey, envInfluxURL)
+		}
+	}
+	return nil
+}
+
+// loadRewrite loads the settings changing the names of written data.
+func loadRewrite(c *Config) (err error) {
+	c.RemoteWriteNamespace = stringOptional(envRemoteWriteNS, sink.DefaultRemoteWriteNamespace)
+	c.MeasurementPrefix = strings.TrimSpace(os.Getenv(envMeasurementPrefix))
+	if c.MeasurementRenames, err = pairsOptional(envMeasurementRename); err != nil {
+		return
+	}
+	if c.TagRenames, err = pairsOptional(envTagRenames); err != nil {
+		return
+	}
+	c.StaticTags, err = pairsOptional(envStaticTags)
+	return
+}
+
+// durationOptional parses an optional duration environment variable like "30s", returning def if unset.
+func durationOptional(envKey string, def time.Duration) (time.Duration, error) {
+	val := strings.TrimSpace(os.Getenv(envKey))
+	if val == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable %s needs to be a duration: %w", envKey, err)
+	}
+	return d, nil
+}
+
+// intOptional parses an optional integer environment variable, returning def if unset.
+func intOptional(envKey string, def int) (int, error) {
+	val := strings.TrimSpace(os.Getenv(envKey))
+	if val == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable %s needs to be an integer: %w", envKey, err)
+	}
+	return i, nil
+}
+
+// stringOptional returns an optional environment variable, def if unset.
+func stringOptional(envKey string, def string) string {
+	if val := strings.TrimSpace(os.Getenv(envKey)); val != "" {
+		return val
+	}
+	return def
+}
+
+// boolOptional parses an optional boolean environment variable, returning def if unset.
+func boolOptional(envKey string, def bool) (bool, error) {
+	val := strings.TrimSpace(os.Getenv(envKey))
+	if val == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("environment variable %s needs to be a boolean: %w", envKey, err)
+	}
+	return b, nil
+}
+
+// splitOptional returns the comma-separated items of an optional environment variable.
+func splitOptional(envKey string) []string {
+	val := strings.TrimSpace(os.Getenv(envKey))
+	if val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
+
+// parseUserGroups parses groups in the format "group1:user1,user2;group2:user3,user4".
+// All group members need to be observed users.
+func parseUserGroups(val string, observed []string) (map[string][]string, error) {
+	groups := map[string][]string{}
+	if strings.TrimSpace(val) == "" {
+		return groups, nil
+	}
+
+	observedSet := make(map[string]struct{}, len(observed))
+	for _, username := range observed {
+		observedSet[strings.ToLower(username)] = struct{}{}
+	}
+
+	for _, groupDef := range strings.Split(val, ";") {
+		if strings.TrimSpace(groupDef) == "" {
+			continue
+		}
+		name, members, found := strings.Cut(groupDef, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid group definition '%s' in %s, expected format name:user1,user2", groupDef, envUserGroups)
+		}
+		if _, exists := groups[name]; exists {
+			return nil, fmt.Errorf("group '%s' defined more than once in %s", name, envUserGroups)
+		}
+		for _, member := range strings.Split(members, ",") {
+			member = strings.TrimSpace(member)
+			if member == "" {
+				continue
+			}
+			if _, ok := observedSet[strings.ToLower(member)]; !ok {
+				return nil, fmt.Errorf("member '%s' of group '%s' is not in %s", member, name, envObservedUsernames)
+			}
+			groups[name] = append(groups[name], member)
+		}
+		if len(groups[name]) == 0 {
+			return nil, fmt.Errorf("group '%s' in %s has no members", name, envUserGroups)
+		}
+	}
+	return groups, nil
+}
+
+// pairsOptional parses an optional environment variable in the format "key1=value1,key2=value2".
+func pairsOptional(envKey string) (map[string]string, error) {
+	pairs, err := ParsePairs(os.Getenv(envKey))
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %s: %w", envKey, err)
+	}
+	return pairs, nil
+}
+
+// ParsePairs parses comma-separated pairs in the format "key1=value1,key2=value2".
+func ParsePairs(val string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(val, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid pair '%s', expected key=value", pair)
+		}
+		pairs[key] = strings.TrimSpace(value)
+	}
+	return pairs, nil
+}
+
+// parseHeaders parses HTTP headers in the format "key1=value1,key2=value2" used by OTEL_EXPORTER_OTLP_HEADERS.
+// Values may be URL-encoded.
+func parseHeaders(val string) (map[string]string, error) {
+	headers, err := ParsePairs(val)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		decoded, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of header '%s': %w", key, err)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
@@ -0,0 +1,379 @@
+// Package dashboard generates Grafana dashboards for the written measurements.
+// Generating fails if a panel references a measurement, field or tag missing from the current schema.
+// The schema lists everything r6prom can write, so panels can still be empty, e.g. for fields removed by the field filter.
+package dashboard
+
+import (
+	"fmt"
+
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// Dashboard formats
+const (
+	FormatFlux       = "flux"
+	FormatPrometheus = "prometheus"
+)
+
+// grafanaSchemaVersion is the version of the Grafana dashboard JSON model the dashboards are generated for.
+const grafanaSchemaVersion = 38
+
+// gridWidth is the width of the Grafana dashboard grid.
+const gridWidth = 24
+
+// Opts configures the generated dashboard.
+type Opts struct {
+	// Format is the query language, either FormatFlux or FormatPrometheus.
+	Format string
+	// Title of the dashboard.
+	Title string
+	// Bucket is the InfluxDB bucket queried by Flux dashboards.
+	Bucket string
+	// Namespace is the prefix of metric names queried by Prometheus dashboards.
+	Namespace string
+	// Rewriter returns the names of measurements and tags as written.
+	Rewriter *metrics.Rewriter
+}
+
+type panelKind int
+
+const (
+	kindTimeSeries panelKind = iota
+	kindStat
+	kindBars
+	// kindMatrix shows the latest values in a table with one row and column per value of two tags, colored like a heatmap.
+	kindMatrix
+)
+
+// variableSpec is a dashboard variable filtering by a tag. Values are taken from field of measurement.
+type variableSpec struct {
+	name        string
+	label       string
+	measurement string
+	field       string
+	tag         string
+}
+
+var variables = []variableSpec{
+	{name: "username", label: "User", measurement: "matches", field: "matches_played", tag: "username"},
+	{name: "season", label: "Season", measurement: "matches", field: "matches_played", tag: "season_slug"},
+	{name: "gamemode", label: "Game mode", measurement: "matches", field: "matches_played", tag: "gamemode"},
+	{name: "role", label: "Team role", measurement: "actions", field: "rounds_played", tag: "role"},
+}
+
+// panelSpec is a single panel showing a field of a measurement.
+type panelSpec struct {
+	title       string
+	kind        panelKind
+	measurement string
+	field       string
+	// filters are names of variables the values are filtered by
+	filters []string
+	// by are the tags distinguishing the shown values, for kindMatrix the row and column tag
+	by    []string
+	unit  string
+	width int
+}
+
+// rowSpec is a row of panels.
+type rowSpec struct {
+	title  string
+	panels []panelSpec
+}
+
+var rows = []rowSpec{
+	{
+		title: "Ranked",
+		panels: []panelSpec{
+			{title: "MMR", kind: kindTimeSeries, measurement: "ranked_combined", field: "mmr", filters: []string{"username"}, by: []string{"username"}, width: 18},
+			{title: "Max MMR", kind: kindStat, measurement: "ranked", field: "max_mmr", filters: []string{"username", "season"}, by: []string{"username"}, width: 6},
+		},
+	},
+	{
+		title: "Maps",
+		panels: []panelSpec{
+			{title: "Matches per map", kind: kindBars, measurement: "maps", field: "matches_played", filters: []string{"username", "season", "gamemode"}, by: []string{"map"}, width: 8},
+			{title: "Win rate per map", kind: kindBars, measurement: "maps", field: "win_rate", filters: []string{"username", "season", "gamemode"}, by: []string{"map"}, unit: "percentunit", width: 8},
+			{title: "K/D per map", kind: kindBars, measurement: "maps", field: "kd_ratio", filters: []string{"username", "season", "gamemode"}, by: []string{"map"}, width: 8},
+		},
+	},
+	{
+		title: "Operators",
+		panels: []panelSpec{
+			{title: "Rounds per operator", kind: kindBars, measurement: "actions", field: "rounds_played", filters: []string{"username", "season", "gamemode", "role"}, by: []string{"operator"}, width: 8},
+			{title: "Round win rate per operator", kind: kindBars, measurement: "actions", field: "round_win_rate", filters: []string{"username", "season", "gamemode", "role"}, by: []string{"operator"}, unit: "percentunit", width: 8},
+			{title: "K/D per operator", kind: kindBars, measurement: "actions", field: "kd_ratio", filters: []string{"username", "season", "gamemode", "role"}, by: []string{"operator"}, width: 8},
+		},
+	},
+	{
+		title: "Bombsites",
+		panels: []panelSpec{
+			{title: "Round win rate per bombsite", kind: kindMatrix, measurement: "bombsites", field: "round_win_rate", filters: []string{"username", "season", "gamemode", "role"}, by: []string{"map", "bombsite"}, unit: "percentunit", width: 12},
+			{title: "K/D per bombsite", kind: kindMatrix, measurement: "bombsites", field: "kd_ratio", filters: []string{"username", "season", "gamemode", "role"}, by: []string{"map", "bombsite"}, width: 12},
+		},
+	},
+}
+
+// ref is a field of a measurement with all names as written.
+type ref struct {
+	measurement string
+	field       string
+	// filters maps written tag keys to variable names
+	filters map[string]string
+	by      []string
+}
+
+// queryBuilder creates the queries for one query language.
+type queryBuilder interface {
+	pluginID() string
+	// series queries the values of r over time.
+	series(r ref) Target
+	// latest queries the last value of every series of r.
+	latest(r ref) Target
+	// table queries the last value of every series of r as a single table.
+	table(r ref) Target
+	// valueColumn is the name of the value column in table results.
+	valueColumn() string
+	// tagColumn is the name of the column of a tag in table results.
+	tagColumn(tag string) string
+	// variable returns the query of the values of a tag.
+	variable(measurement string, field string, tag string) string
+	// displayName returns the display name of the values of r, empty for the default name.
+	displayName(r ref) string
+}
+
+// Generate creates a dashboard.
+func Generate(opts Opts) (Dashboard, error) {
+	var qb queryBuilder
+	switch opts.Format {
+	case FormatFlux:
+		qb = fluxBuilder{bucket: opts.Bucket}
+	case FormatPrometheus:
+		qb = promBuilder{namespace: opts.Namespace}
+	default:
+		return Dashboard{}, fmt.Errorf("unsupported dashboard format '%s', expected %s or %s", opts.Format, FormatFlux, FormatPrometheus)
+	}
+	g := &generator{
+		qb:       qb,
+		rewriter: opts.Rewriter,
+		schema:   opts.Rewriter.ApplySchema(metrics.CurrentSchema()),
+		ds:       &Datasource{Type: qb.pluginID(), UID: "${datasource}"},
+		vars:     map[string]variableSpec{},
+	}
+	return g.dashboard(opts)
+}
+
+type generator struct {
+	qb       queryBuilder
+	rewriter *metrics.Rewriter
+	schema   metrics.Schema
+	ds       *Datasource
+	vars     map[string]variableSpec
+
+	nextID int
+	x, y   int
+	rowH   int
+}
+
+func (g *generator) dashboard(opts Opts) (Dashboard, error) {
+	d := Dashboard{
+		UID:           "r6prom-" + opts.Format,
+		Title:         opts.Title,
+		Tags:          []string{"r6prom", "rainbow-six-siege"},
+		Timezone:      "browser",
+		SchemaVersion: grafanaSchemaVersion,
+		Editable:      true,
+		Refresh:       "5m",
+		Time:          TimeRange{From: "now-30d", To: "now"},
+	}
+
+	d.Templating.List = append(d.Templating.List, Variable{
+		Name:  "datasource",
+		Label: "Data source",
+		Type:  "datasource",
+		Query: g.qb.pluginID(),
+	})
+	for _, v := range variables {
+		measurement, err := g.measurement(v.measurement, v.field, v.tag)
+		if err != nil {
+			return Dashboard{}, fmt.Errorf("variable %s: %w", v.name, err)
+		}
+		query := g.qb.variable(measurement, v.field, g.rewriter.TagKey(v.tag))
+		d.Templating.List = append(d.Templating.List, Variable{
+			Name:       v.name,
+			Label:      v.label,
+			Type:       "query",
+			Query:      query,
+			Definition: query,
+			Datasource: g.ds,
+			// refresh on time range change
+			Refresh: 2,
+			// alphabetical, case-insensitive
+			Sort: 5,
+		})
+		g.vars[v.name] = v
+	}
+
+	for _, row := range rows {
+		d.Panels = append(d.Panels, g.row(row.title))
+		for _, spec := range row.panels {
+			panel, err := g.panel(spec)
+			if err != nil {
+				return Dashboard{}, fmt.Errorf("panel '%s': %w", spec.title, err)
+			}
+			d.Panels = append(d.Panels, panel)
+		}
+	}
+	return d, nil
+}
+
+// measurement returns the written name of a measurement after checking that it has the field and tags.
+func (g *generator) measurement(name string, field string, tags ...string) (string, error) {
+	written := g.rewriter.Measurement(name)
+	m, ok := g.schema.Measurement(written)
+	if !ok {
+		return "", fmt.Errorf("measurement %s is not part of schema version %d", written, g.schema.Version)
+	}
+	hasField := false
+	for _, f := range m.Fields {
+		if f.Name == field {
+			hasField = true
+			break
+		}
+	}
+	if !hasField {
+		return "", fmt.Errorf("measurement %s has no field %s", written, field)
+	}
+	for _, tag := range tags {
+		tag = g.rewriter.TagKey(tag)
+		hasTag := false
+		for _, t := range m.Tags {
+			if t == tag {
+				hasTag = true
+				break
+			}
+		}
+		if !hasTag {
+			return "", fmt.Errorf("measurement %s has no tag %s", written, tag)
+		}
+	}
+	return written, nil
+}
+
+func (g *generator) resolve(spec panelSpec) (ref, error) {
+	r := ref{field: spec.field, filters: map[string]string{}}
+	tags := make([]string, 0, len(spec.filters)+len(spec.by))
+	for _, name := range spec.filters {
+		v, ok := g.vars[name]
+		if !ok {
+			return ref{}, fmt.Errorf("unknown variable %s", name)
+		}
+		tags = append(tags, v.tag)
+		r.filters[g.rewriter.TagKey(v.tag)] = name
+	}
+	for _, tag := range spec.by {
+		tags = append(tags, tag)
+		r.by = append(r.by, g.rewriter.TagKey(tag))
+	}
+	measurement, err := g.measurement(spec.measurement, spec.field, tags...)
+	if err != nil {
+		return ref{}, err
+	}
+	r.measurement = measurement
+	return r, nil
+}
+
+// place returns the position of the next panel, starting a new line if it doesn't fit into the current one.
+func (g *generator) place(w int, h int) GridPos {
+	if g.x+w > gridWidth {
+		g.x = 0
+		g.y += g.rowH
+		g.rowH = 0
+	}
+	pos := GridPos{X: g.x, Y: g.y, W: w, H: h}
+	g.x += w
+	if h > g.rowH {
+		g.rowH = h
+	}
+	g.nextID++
+	return pos
+}
+
+func (g *generator) row(title string) Panel {
+	g.x = gridWidth
+	pos := g.place(gridWidth, 1)
+	return Panel{ID: g.nextID, Type: "row", Title: title, GridPos: pos}
+}
+
+func (g *generator) panel(spec panelSpec) (Panel, error) {
+	r, err := g.resolve(spec)
+	if err != nil {
+		return Panel{}, err
+	}
+	defaults := map[string]interface{}{}
+	if spec.unit != "" {
+		defaults["unit"] = spec.unit
+	}
+	if name := g.qb.displayName(r); name != "" {
+		defaults["displayName"] = name
+	}
+	lastValue := map[string]interface{}{"calcs": []string{"lastNotNull"}, "fields": "", "values": false}
+
+	p := Panel{
+		Title:       spec.title,
+		Datasource:  g.ds,
+		FieldConfig: &FieldConfig{Defaults: defaults, Overrides: []map[string]interface{}{}},
+	}
+	var target Target
+	switch spec.kind {
+	case kindTimeSeries:
+		p.Type = "timeseries"
+		p.GridPos = g.place(spec.width, 9)
+		target = g.qb.series(r)
+		p.Options = map[string]interface{}{
+			"legend":  map[string]interface{}{"displayMode": "list", "placement": "bottom", "showLegend": true},
+			"tooltip": map[string]interface{}{"mode": "multi", "sort": "desc"},
+		}
+	case kindStat:
+		p.Type = "stat"
+		p.GridPos = g.place(spec.width, 9)
+		target = g.qb.latest(r)
+		p.Options = map[string]interface{}{"reduceOptions": lastValue, "colorMode": "value", "graphMode": "none"}
+	case kindBars:
+		p.Type = "bargauge"
+		p.GridPos = g.place(spec.width, 12)
+		target = g.qb.latest(r)
+		p.Options = map[string]interface{}{
+			"reduceOptions": lastValue,
+			"orientation":   "horizontal",
+			"displayMode":   "gradient",
+			"showUnfilled":  true,
+		}
+	case kindMatrix:
+		if len(r.by) != 2 {
+			return Panel{}, fmt.Errorf("matrix needs a row and a column tag, got %d tags", len(r.by))
+		}
+		p.Type = "table"
+		p.GridPos = g.place(spec.width, 12)
+		target = g.qb.table(r)
+		delete(defaults, "displayName")
+		defaults["custom"] = map[string]interface{}{
+			"align":       "center",
+			"cellOptions": map[string]interface{}{"type": "color-background"},
+		}
+		defaults["color"] = map[string]interface{}{"mode": "continuous-RdYlGr"}
+		p.Transformations = []Transformation{{
+			ID: "groupingToMatrix",
+			Options: map[string]interface{}{
+				"rowField":    g.qb.tagColumn(r.by[0]),
+				"columnField": g.qb.tagColumn(r.by[1]),
+				"valueField":  g.qb.valueColumn(),
+			},
+		}}
+	}
+	p.ID = g.nextID
+	target.RefID = "A"
+	target.Datasource = g.ds
+	p.Targets = []Target{target}
+	return p, nil
+}
@@ -0,0 +1,106 @@
+package dashboard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stnokott/r6prom/metrics"
+)
+
+func TestGenerate(t *testing.T) {
+	rewriter := metrics.NewRewriter("r6_", map[string]string{"actions": "operators"}, map[string]string{"username": "player"}, nil)
+	tests := []struct {
+		format string
+		// query returns the query of a target in the format
+		query func(Target) string
+		want  []string
+	}{
+		{
+			format: FormatFlux,
+			query:  func(t Target) string { return t.Query },
+			want:   []string{`r._measurement == "r6_operators"`, `r["player"] == "${username}"`, `from(bucket: "bucket")`},
+		},
+		{
+			format: FormatPrometheus,
+			query:  func(t Target) string { return t.Expr },
+			want:   []string{"r6prom_r6_operators_rounds_played{", `player="$username"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			d, err := Generate(Opts{Format: tt.format, Title: "r6prom", Bucket: "bucket", Namespace: "r6prom", Rewriter: rewriter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Templating.List) != len(variables)+1 {
+				t.Errorf("got %d variables, want %d", len(d.Templating.List), len(variables)+1)
+			}
+
+			var queries []string
+			panels, ids := 0, map[int]bool{}
+			for _, p := range d.Panels {
+				if ids[p.ID] {
+					t.Errorf("panel ID %d used twice", p.ID)
+				}
+				ids[p.ID] = true
+				if p.GridPos.X+p.GridPos.W > gridWidth {
+					t.Errorf("panel '%s' exceeds the grid: %+v", p.Title, p.GridPos)
+				}
+				if p.Type == "row" {
+					continue
+				}
+				panels++
+				if len(p.Targets) != 1 || tt.query(p.Targets[0]) == "" {
+					t.Errorf("panel '%s' has no query", p.Title)
+					continue
+				}
+				queries = append(queries, tt.query(p.Targets[0]))
+			}
+			wantPanels := 0
+			for _, row := range rows {
+				wantPanels += len(row.panels)
+			}
+			if panels != wantPanels {
+				t.Errorf("got %d panels, want %d", panels, wantPanels)
+			}
+
+			all := strings.Join(queries, "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(all, want) {
+					t.Errorf("queries do not contain %s", want)
+				}
+			}
+			// only the written names are referenced
+			for _, unwanted := range []string{`"actions"`, "_actions_", `"username"`, "username="} {
+				if strings.Contains(all, unwanted) {
+					t.Errorf("queries contain original name %s", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	if _, err := Generate(Opts{Format: "graphite"}); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+
+	schema := metrics.CurrentSchema()
+	g := &generator{schema: schema}
+	tests := []struct {
+		measurement string
+		field       string
+		tags        []string
+		wantErr     bool
+	}{
+		{"maps", "kd_ratio", []string{"username", "map"}, false},
+		{"operators", "kd_ratio", nil, true},
+		{"maps", "mmr", nil, true},
+		{"maps", "kd_ratio", []string{"operator"}, true},
+	}
+	for _, tt := range tests {
+		if _, err := g.measurement(tt.measurement, tt.field, tt.tags...); (err != nil) != tt.wantErr {
+			t.Errorf("measurement(%s, %s, %v) error = %v, want error %t", tt.measurement, tt.field, tt.tags, err, tt.wantErr)
+		}
+	}
+}
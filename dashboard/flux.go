@@ -0,0 +1,93 @@
+package dashboard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fluxBuilder creates Flux queries for the InfluxDB datasource.
+type fluxBuilder struct {
+	bucket string
+}
+
+func (fluxBuilder) pluginID() string {
+	return "influxdb"
+}
+
+// from returns the query of all points of r in the dashboard time range, filtered by the variables.
+func (f fluxBuilder) from(r ref) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "from(bucket: %s)\n", strconv.Quote(f.bucket))
+	b.WriteString("  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)\n")
+	fmt.Fprintf(&b, "  |> filter(fn: (r) => r._measurement == %s and r._field == %s)", strconv.Quote(r.measurement), strconv.Quote(r.field))
+
+	tags := make([]string, 0, len(r.filters))
+	for tag := range r.filters {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	conditions := make([]string, len(tags))
+	for i, tag := range tags {
+		conditions[i] = fmt.Sprintf("r[%s] == %s", strconv.Quote(tag), strconv.Quote("${"+r.filters[tag]+"}"))
+	}
+	if len(conditions) > 0 {
+		fmt.Fprintf(&b, "\n  |> filter(fn: (r) => %s)", strings.Join(conditions, " and "))
+	}
+	return b.String()
+}
+
+// keep returns the keep() call for the value column and the tags of r.
+func keep(r ref, columns ...string) string {
+	columns = append(columns, "_value")
+	columns = append(columns, r.by...)
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = strconv.Quote(c)
+	}
+	return fmt.Sprintf("\n  |> keep(columns: [%s])", strings.Join(quoted, ", "))
+}
+
+func (f fluxBuilder) series(r ref) Target {
+	query := f.from(r) +
+		"\n  |> aggregateWindow(every: v.windowPeriod, fn: last, createEmpty: false)" +
+		keep(r, "_time")
+	return Target{Query: query}
+}
+
+func (f fluxBuilder) latest(r ref) Target {
+	return Target{Query: f.from(r) + "\n  |> last()" + keep(r)}
+}
+
+func (f fluxBuilder) table(r ref) Target {
+	return Target{Query: f.from(r) + "\n  |> last()\n  |> group()" + keep(r)}
+}
+
+func (fluxBuilder) valueColumn() string {
+	return "_value"
+}
+
+func (fluxBuilder) tagColumn(tag string) string {
+	return tag
+}
+
+func (f fluxBuilder) variable(measurement string, field string, tag string) string {
+	return fmt.Sprintf(`import "influxdata/influxdb/schema"
+
+schema.tagValues(
+  bucket: %s,
+  tag: %s,
+  predicate: (r) => r._measurement == %s and r._field == %s,
+  start: v.timeRangeStart,
+  stop: v.timeRangeStop,
+)`, strconv.Quote(f.bucket), strconv.Quote(tag), strconv.Quote(measurement), strconv.Quote(field))
+}
+
+func (fluxBuilder) displayName(r ref) string {
+	names := make([]string, len(r.by))
+	for i, tag := range r.by {
+		names[i] = "${__field.labels." + tag + "}"
+	}
+	return strings.Join(names, " ")
+}
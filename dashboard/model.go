@@ -0,0 +1,94 @@
+package dashboard
+
+// Dashboard is the subset of the Grafana dashboard JSON model used by the generated dashboards.
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Editable      bool       `json:"editable"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+// TimeRange is the default time range of a dashboard.
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Templating holds the dashboard variables.
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a dashboard variable.
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Query      interface{} `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Definition string      `json:"definition,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+	Sort       int         `json:"sort,omitempty"`
+	Multi      bool        `json:"multi"`
+	IncludeAll bool        `json:"includeAll"`
+}
+
+// Datasource references a Grafana datasource.
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// GridPos is the position and size of a panel in the dashboard grid, which is 24 units wide.
+type GridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Panel is a dashboard panel or row.
+type Panel struct {
+	ID              int                    `json:"id"`
+	Type            string                 `json:"type"`
+	Title           string                 `json:"title"`
+	GridPos         GridPos                `json:"gridPos"`
+	Datasource      *Datasource            `json:"datasource,omitempty"`
+	Targets         []Target               `json:"targets,omitempty"`
+	FieldConfig     *FieldConfig           `json:"fieldConfig,omitempty"`
+	Options         map[string]interface{} `json:"options,omitempty"`
+	Transformations []Transformation       `json:"transformations,omitempty"`
+	Collapsed       bool                   `json:"collapsed,omitempty"`
+}
+
+// Target is a query of a panel.
+type Target struct {
+	RefID      string      `json:"refId"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	// Query is the Flux query
+	Query string `json:"query,omitempty"`
+	// Expr is the PromQL expression
+	Expr         string `json:"expr,omitempty"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+	Range        bool   `json:"range,omitempty"`
+	Format       string `json:"format,omitempty"`
+}
+
+// FieldConfig configures how the values of a panel are displayed.
+type FieldConfig struct {
+	Defaults  map[string]interface{}   `json:"defaults"`
+	Overrides []map[string]interface{} `json:"overrides"`
+}
+
+// Transformation transforms the query results of a panel.
+type Transformation struct {
+	ID      string                 `json:"id"`
+	Options map[string]interface{} `json:"options"`
+}
@@ -0,0 +1,71 @@
+package dashboard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stnokott/r6prom/sink"
+)
+
+// promBuilder creates PromQL queries for the Prometheus datasource, matching the names written by the remote-write sink.
+type promBuilder struct {
+	namespace string
+}
+
+func (promBuilder) pluginID() string {
+	return "prometheus"
+}
+
+// selector returns the selector of the metric of r, filtered by the variables.
+func (p promBuilder) selector(r ref) string {
+	tags := make([]string, 0, len(r.filters))
+	for tag := range r.filters {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	matchers := make([]string, len(tags))
+	for i, tag := range tags {
+		matchers[i] = fmt.Sprintf(`%s="$%s"`, sink.PrometheusLabelName(tag), r.filters[tag])
+	}
+	return fmt.Sprintf("%s{%s}", sink.PrometheusMetricName(p.namespace, r.measurement, r.field), strings.Join(matchers, ","))
+}
+
+// legend returns the legend format showing the tags of r.
+func legend(r ref) string {
+	names := make([]string, len(r.by))
+	for i, tag := range r.by {
+		names[i] = "{{" + sink.PrometheusLabelName(tag) + "}}"
+	}
+	return strings.Join(names, " ")
+}
+
+func (p promBuilder) series(r ref) Target {
+	return Target{Expr: p.selector(r), LegendFormat: legend(r), Range: true}
+}
+
+func (p promBuilder) latest(r ref) Target {
+	return Target{Expr: fmt.Sprintf("last_over_time(%s[$__range])", p.selector(r)), LegendFormat: legend(r), Instant: true}
+}
+
+func (p promBuilder) table(r ref) Target {
+	target := p.latest(r)
+	target.Format = "table"
+	return target
+}
+
+func (promBuilder) valueColumn() string {
+	return "Value"
+}
+
+func (promBuilder) tagColumn(tag string) string {
+	return sink.PrometheusLabelName(tag)
+}
+
+func (p promBuilder) variable(measurement string, field string, tag string) string {
+	return fmt.Sprintf("label_values(%s, %s)", sink.PrometheusMetricName(p.namespace, measurement, field), sink.PrometheusLabelName(tag))
+}
+
+func (promBuilder) displayName(ref) string {
+	return ""
+}
@@ -0,0 +1,162 @@
+// Package enrich normalizes names in tags and adds static metadata like operator sides or the ranked map pool to points.
+package enrich
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+//go:embed data/*.json
+var bundled embed.FS
+
+const (
+	operatorsFile = "data/operators.json"
+	mapsFile      = "data/maps.json"
+)
+
+// OperatorInfo holds static information about an operator.
+type OperatorInfo struct {
+	// Side is "attack" or "defence".
+	Side string `json:"side"`
+	// Role is the playstyle of the operator, e.g. "breach" or "anchor".
+	Role string `json:"role"`
+}
+
+type operatorsData struct {
+	Operators map[string]OperatorInfo `json:"operators"`
+}
+
+type mapsData struct {
+	Maps       []string `json:"maps"`
+	RankedPool []string `json:"ranked_pool"`
+}
+
+// rankFields maps numeric rank fields to the prefix of the added name and tier fields.
+var rankFields = map[string]string{
+	"rank":     "rank",
+	"max_rank": "max_rank",
+}
+
+// rankMeasurements are the measurements whose rank fields hold rank ordinals.
+// Other measurements use rank fields differently, e.g. for the leaderboard position.
+var rankMeasurements = map[string]bool{
+	"ranked":          true,
+	"ranked_combined": true,
+}
+
+// Enricher adds tags and fields derived from static data to points:
+//   - "side" and "operator_role" tags to points with an operator tag
+//   - a boolean "ranked_pool" field to points with a map tag
+//   - "<rank>_name" and "<rank>_tier" fields to ranked and ranked_combined points with numeric rank fields
+type Enricher struct {
+	operators  map[string]OperatorInfo
+	rankedPool map[string]bool
+}
+
+// New creates an enricher from the given data files. Bundled data is used for empty paths.
+func New(operatorsPath string, mapsPath string) (*Enricher, error) {
+	var ops operatorsData
+	if err := loadData(operatorsPath, operatorsFile, &ops); err != nil {
+		return nil, fmt.Errorf("could not load operator data: %w", err)
+	}
+	var maps mapsData
+	if err := loadData(mapsPath, mapsFile, &maps); err != nil {
+		return nil, fmt.Errorf("could not load map data: %w", err)
+	}
+
+	e := &Enricher{
+		operators:  make(map[string]OperatorInfo, len(ops.Operators)),
+		rankedPool: make(map[string]bool, len(maps.RankedPool)),
+	}
+	for name, info := range ops.Operators {
+		e.operators[fold(name)] = info
+	}
+	for _, name := range maps.RankedPool {
+		e.rankedPool[fold(name)] = true
+	}
+	return e, nil
+}
+
+// loadData decodes the JSON file at path, or the bundled file if path is empty.
+func loadData(path string, bundledPath string, v interface{}) error {
+	var b []byte
+	var err error
+	if path == "" {
+		b, err = bundled.ReadFile(bundledPath)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Operator returns the information about an operator, ok is false if the operator is unknown.
+func (e *Enricher) Operator(name string) (info OperatorInfo, ok bool) {
+	info, ok = e.operators[fold(name)]
+	return
+}
+
+// InRankedPool reports whether a map is part of the ranked map pool.
+func (e *Enricher) InRankedPool(name string) bool {
+	return e.rankedPool[fold(name)]
+}
+
+// Apply returns p with added tags and fields. If nothing is added, p is returned unchanged.
+func (e *Enricher) Apply(p *write.Point) *write.Point {
+	if e == nil {
+		return p
+	}
+	tags := metrics.PointTags(p)
+	fields := metrics.PointFields(p)
+	changed := false
+
+	if operator, ok := tags["operator"]; ok {
+		if info, known := e.Operator(operator); known {
+			tags["side"] = info.Side
+			tags["operator_role"] = info.Role
+			changed = true
+		}
+	}
+	if mapName, ok := tags["map"]; ok {
+		fields["ranked_pool"] = e.InRankedPool(mapName)
+		changed = true
+	}
+	if rankMeasurements[p.Name()] && addRankNames(fields) {
+		changed = true
+	}
+
+	if !changed {
+		return p
+	}
+	return influxdb2.NewPoint(p.Name(), tags, fields, p.Time())
+}
+
+// addRankNames adds the name and tier fields of all numeric rank fields and reports whether any were added.
+func addRankNames(fields map[string]interface{}) bool {
+	added := false
+	for field, prefix := range rankFields {
+		if _, exists := fields[prefix+"_name"]; exists {
+			continue
+		}
+		ordinal, ok := metrics.FloatField(fields, field)
+		if !ok {
+			continue
+		}
+		rank, err := metrics.RankFromOrdinal(int(ordinal))
+		if err != nil {
+			continue
+		}
+		fields[prefix+"_name"] = rank.String()
+		fields[prefix+"_tier"] = rank.Tier
+		added = true
+	}
+	return added
+}
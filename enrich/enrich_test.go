@@ -0,0 +1,154 @@
+package enrich
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+func TestEnricherApply(t *testing.T) {
+	e, err := New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name        string
+		measurement string
+		tags        map[string]string
+		fields      map[string]interface{}
+		wantTags    map[string]string
+		wantFields  map[string]interface{}
+	}{
+		{
+			name:        "known operator",
+			measurement: "actions",
+			tags:        map[string]string{"operator": "Thatcher"},
+			fields:      map[string]interface{}{"kills": 1},
+			wantTags:    map[string]string{"operator": "Thatcher", "side": "attack", "operator_role": "anti_gadget"},
+			wantFields:  map[string]interface{}{"kills": int64(1)},
+		},
+		{
+			name:        "unknown operator",
+			measurement: "actions",
+			tags:        map[string]string{"operator": "Nobody"},
+			fields:      map[string]interface{}{"kills": 1},
+			wantTags:    map[string]string{"operator": "Nobody"},
+			wantFields:  map[string]interface{}{"kills": int64(1)},
+		},
+		{
+			name:        "ranked map",
+			measurement: "maps",
+			tags:        map[string]string{"map": "Oregon"},
+			fields:      map[string]interface{}{"kills": 1},
+			wantTags:    map[string]string{"map": "Oregon"},
+			wantFields:  map[string]interface{}{"kills": int64(1), "ranked_pool": true},
+		},
+		{
+			name:        "unknown map",
+			measurement: "maps",
+			tags:        map[string]string{"map": "Nowhere"},
+			fields:      map[string]interface{}{"kills": 1},
+			wantTags:    map[string]string{"map": "Nowhere"},
+			wantFields:  map[string]interface{}{"kills": int64(1), "ranked_pool": false},
+		},
+		{
+			name:        "rank names",
+			measurement: "ranked",
+			fields:      map[string]interface{}{"rank": 20, "max_rank": 36},
+			wantTags:    map[string]string{},
+			wantFields:  map[string]interface{}{"rank": int64(20), "rank_name": "Gold I", "rank_tier": "gold", "max_rank": int64(36), "max_rank_name": "Champion", "max_rank_tier": "champion"},
+		},
+		{
+			name:        "unranked",
+			measurement: "ranked_combined",
+			fields:      map[string]interface{}{"rank": 0},
+			wantTags:    map[string]string{},
+			wantFields:  map[string]interface{}{"rank": int64(0), "rank_name": "Unranked", "rank_tier": "unranked"},
+		},
+		{
+			name:        "invalid rank",
+			measurement: "ranked",
+			fields:      map[string]interface{}{"rank": 99},
+			wantTags:    map[string]string{},
+			wantFields:  map[string]interface{}{"rank": int64(99)},
+		},
+		{
+			name:        "existing rank name",
+			measurement: "ranked",
+			fields:      map[string]interface{}{"rank": 20, "rank_name": "Gold 1"},
+			wantTags:    map[string]string{},
+			wantFields:  map[string]interface{}{"rank": int64(20), "rank_name": "Gold 1"},
+		},
+		{
+			name:        "rank of other measurements",
+			measurement: "leaderboard",
+			fields:      map[string]interface{}{"rank": 1},
+			wantTags:    map[string]string{},
+			wantFields:  map[string]interface{}{"rank": int64(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := e.Apply(influxdb2.NewPoint(tt.measurement, tt.tags, tt.fields, ts))
+			if tags := metrics.PointTags(p); !reflect.DeepEqual(tags, tt.wantTags) {
+				t.Errorf("tags = %v, want %v", tags, tt.wantTags)
+			}
+			if fields := metrics.PointFields(p); !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestEnricherDataFiles(t *testing.T) {
+	dir := t.TempDir()
+	operatorsPath := filepath.Join(dir, "operators.json")
+	mapsPath := filepath.Join(dir, "maps.json")
+	if err := os.WriteFile(operatorsPath, []byte(`{"operators": {"Newop": {"side": "defence", "role": "anchor"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mapsPath, []byte(`{"maps": ["Oregon", "New Map"], "ranked_pool": ["New Map"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := New(operatorsPath, mapsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the files replace the bundled data
+	if info, ok := e.Operator("newop"); !ok || info.Side != "defence" || info.Role != "anchor" {
+		t.Errorf("Operator(newop) = %+v, %t, want defence anchor", info, ok)
+	}
+	if _, ok := e.Operator("Thatcher"); ok {
+		t.Error("bundled operator known with an operators file")
+	}
+	if !e.InRankedPool("new map") || e.InRankedPool("Oregon") {
+		t.Error("ranked pool not taken from the maps file")
+	}
+
+	// only the given file is replaced
+	if e, err = New("", mapsPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Operator("Thatcher"); !ok {
+		t.Error("bundled operator unknown without an operators file")
+	}
+
+	invalidPath := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalidPath, []byte(`{"operators": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(invalidPath, ""); err == nil {
+		t.Error("New() accepted an invalid operators file")
+	}
+	if _, err := New(filepath.Join(dir, "missing.json"), ""); err == nil {
+		t.Error("New() accepted a missing operators file")
+	}
+}
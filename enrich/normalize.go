@@ -0,0 +1,175 @@
+package enrich
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+const aliasesFile = "data/aliases.json"
+
+// NormalizedTags are the tags whose values are normalized.
+var NormalizedTags = []string{"map", "operator", "bombsite"}
+
+// foldReplacer removes diacritics of characters found in map and operator names.
+var foldReplacer = strings.NewReplacer(
+	"ä", "a", "ã", "a", "á", "a", "à", "a", "â", "a",
+	"é", "e", "è", "e", "ê", "e",
+	"í", "i", "ï", "i",
+	"ö", "o", "ø", "o", "ó", "o", "õ", "o", "ô", "o",
+	"ü", "u", "ú", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// fold converts a name into a comparable form, ignoring casing, diacritics, spacing and separators.
+func fold(name string) string {
+	name = foldReplacer.Replace(strings.ToLower(name))
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || r == '-' || r == '.'
+	}), " ")
+}
+
+// UnknownFunc is called once for every name which is neither a known name nor an alias.
+// For tags without a list of known names, like bombsites, it is called for names which only differ
+// in spelling from a previously seen name.
+type UnknownFunc func(tag string, name string)
+
+// Normalizer maps differently spelled or renamed maps, operators and bombsites to a single canonical name,
+// so that API changes don't split series.
+type Normalizer struct {
+	// aliases maps tag key to folded name to canonical name
+	aliases map[string]map[string]string
+	// known contains the tag keys for which all canonical names are known
+	known     map[string]bool
+	onUnknown UnknownFunc
+
+	mu       sync.Mutex
+	reported map[string]bool
+	// seen maps tag key to folded name to the first seen spelling, for tags without known names
+	seen map[string]map[string]string
+}
+
+// NewNormalizer creates a normalizer from an alias file and the known operators and maps of the enrichment data files.
+// Bundled data is used for empty paths.
+func NewNormalizer(aliasesPath string, operatorsPath string, mapsPath string, onUnknown UnknownFunc) (*Normalizer, error) {
+	var aliases map[string]map[string]string
+	if err := loadData(aliasesPath, aliasesFile, &aliases); err != nil {
+		return nil, fmt.Errorf("could not load aliases: %w", err)
+	}
+	var ops operatorsData
+	if err := loadData(operatorsPath, operatorsFile, &ops); err != nil {
+		return nil, fmt.Errorf("could not load operator data: %w", err)
+	}
+	var maps mapsData
+	if err := loadData(mapsPath, mapsFile, &maps); err != nil {
+		return nil, fmt.Errorf("could not load map data: %w", err)
+	}
+
+	n := &Normalizer{
+		aliases:   map[string]map[string]string{},
+		known:     map[string]bool{},
+		onUnknown: onUnknown,
+		reported:  map[string]bool{},
+		seen:      map[string]map[string]string{},
+	}
+	for _, tag := range NormalizedTags {
+		n.aliases[tag] = map[string]string{}
+	}
+	canonical := map[string][]string{"map": maps.Maps}
+	for name := range ops.Operators {
+		canonical["operator"] = append(canonical["operator"], name)
+	}
+	for tag, names := range canonical {
+		if len(names) == 0 {
+			continue
+		}
+		n.known[tag] = true
+		for _, name := range names {
+			n.aliases[tag][fold(name)] = name
+		}
+	}
+	for tag, tagAliases := range aliases {
+		if _, ok := n.aliases[tag]; !ok {
+			return nil, fmt.Errorf("aliases for unsupported tag '%s', expected one of %s", tag, strings.Join(NormalizedTags, ", "))
+		}
+		for alias, name := range tagAliases {
+			n.aliases[tag][fold(alias)] = name
+		}
+	}
+	return n, nil
+}
+
+// Normalize returns the canonical name for a tag value.
+// Unknown names are returned with surrounding and repeated whitespace removed.
+func (n *Normalizer) Normalize(tag string, name string) string {
+	if name, ok := n.aliases[tag][fold(name)]; ok {
+		return name
+	}
+	trimmed := strings.Join(strings.Fields(name), " ")
+	if n.known[tag] {
+		n.reportUnknown(tag, name)
+	} else if n.isSpellingVariant(tag, trimmed) {
+		n.reportUnknown(tag, name)
+	}
+	return trimmed
+}
+
+// isSpellingVariant records name and reports whether a different spelling of it was seen before.
+func (n *Normalizer) isSpellingVariant(tag string, name string) bool {
+	folded := fold(name)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	tagSeen, ok := n.seen[tag]
+	if !ok {
+		tagSeen = map[string]string{}
+		n.seen[tag] = tagSeen
+	}
+	first, ok := tagSeen[folded]
+	if !ok {
+		tagSeen[folded] = name
+		return false
+	}
+	return first != name
+}
+
+func (n *Normalizer) reportUnknown(tag string, name string) {
+	if n.onUnknown == nil {
+		return
+	}
+	n.mu.Lock()
+	key := tag + "/" + name
+	first := !n.reported[key]
+	n.reported[key] = true
+	n.mu.Unlock()
+	if first {
+		n.onUnknown(tag, name)
+	}
+}
+
+// Apply returns p with normalized tags. If no tag changes, p is returned unchanged.
+func (n *Normalizer) Apply(p *write.Point) *write.Point {
+	if n == nil {
+		return p
+	}
+	var tags map[string]string
+	for _, tag := range p.TagList() {
+		if _, ok := n.aliases[tag.Key]; !ok {
+			continue
+		}
+		if normalized := n.Normalize(tag.Key, tag.Value); normalized != tag.Value {
+			if tags == nil {
+				tags = metrics.PointTags(p)
+			}
+			tags[tag.Key] = normalized
+		}
+	}
+	if tags == nil {
+		return p
+	}
+	return influxdb2.NewPoint(p.Name(), tags, metrics.PointFields(p), p.Time())
+}
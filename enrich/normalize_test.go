@@ -0,0 +1,108 @@
+package enrich
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Jäger", "jager"},
+		{"  Club_House ", "club house"},
+		{"Kafe-Dostoyevsky", "kafe dostoyevsky"},
+		{"NØKK", "nokk"},
+		{"Capitão", "capitao"},
+	}
+	for _, tt := range tests {
+		if got := fold(tt.in); got != tt.want {
+			t.Errorf("fold(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	var unknown []string
+	n, err := NewNormalizer("", "", "", func(tag string, name string) {
+		unknown = append(unknown, tag+"/"+name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tag  string
+		name string
+		want string
+	}{
+		{"operator", "Jäger", "Jäger"},
+		{"operator", "Jager", "Jäger"},
+		{"operator", "JAEGER", "Jäger"},
+		{"operator", "nokk", "Nøkk"},
+		{"map", "Kafe", "Kafe Dostoyevsky"},
+		{"map", "club_house", "Clubhouse"},
+		{"map", " Oregon ", "Oregon"},
+		{"map", "New  Map", "New Map"},
+		{"map", "New Map", "New Map"},
+		{"bombsite", "2F Kitchen", "2F Kitchen"},
+		{"bombsite", "2F  Kitchen", "2F Kitchen"},
+		{"bombsite", "2f kitchen", "2f kitchen"},
+		{"bombsite", "B Basement", "B Basement"},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.tag, tt.name); got != tt.want {
+			t.Errorf("Normalize(%q, %q) = %q, want %q", tt.tag, tt.name, got, tt.want)
+		}
+	}
+
+	// unknown maps are reported once per spelling, bombsites only if they differ in spelling from a previous one
+	want := []string{"map/New  Map", "map/New Map", "bombsite/2f kitchen"}
+	if !reflect.DeepEqual(unknown, want) {
+		t.Errorf("reported unknown names %v, want %v", unknown, want)
+	}
+}
+
+func TestNormalizerApply(t *testing.T) {
+	n, err := NewNormalizer("", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1700000000, 0)
+
+	p := influxdb2.NewPoint("actions", map[string]string{"operator": "Jäger", "username": "Jager"}, map[string]interface{}{"kills": 1}, ts)
+	if got := n.Apply(p); got != p {
+		t.Error("point without changes should be returned unchanged")
+	}
+
+	p = influxdb2.NewPoint("actions", map[string]string{"operator": "Jager", "username": "Jager"}, map[string]interface{}{"kills": 1}, ts)
+	got := n.Apply(p)
+	if want := map[string]string{"operator": "Jäger", "username": "Jager"}; !reflect.DeepEqual(metrics.PointTags(got), want) {
+		t.Errorf("tags = %v, want %v", metrics.PointTags(got), want)
+	}
+	if !reflect.DeepEqual(metrics.PointFields(got), metrics.PointFields(p)) || !got.Time().Equal(ts) || got.Name() != "actions" {
+		t.Errorf("normalized point changed name, fields or time: %v", got)
+	}
+
+	var nilNormalizer *Normalizer
+	if got := nilNormalizer.Apply(p); got != p {
+		t.Error("nil normalizer should return the point unchanged")
+	}
+}
+
+func TestNewNormalizerUnsupportedTag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	if err := os.WriteFile(path, []byte(`{"weapon": {"a": "b"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewNormalizer(path, "", "", nil); err == nil {
+		t.Error("expected error for aliases of unsupported tag")
+	}
+}
@@ -1,19 +1,20 @@
 package main
 
 import (
-	"context"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
-	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
-	"github.com/influxdata/influxdb-client-go/v2/domain"
-	influxlog "github.com/influxdata/influxdb-client-go/v2/log"
 	"github.com/rs/zerolog"
 	"github.com/stnokott/r6api"
 	"github.com/stnokott/r6prom/config"
 	"github.com/stnokott/r6prom/constants"
+	"github.com/stnokott/r6prom/enrich"
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/snapshot"
 	"github.com/stnokott/r6prom/store"
+	"github.com/stnokott/r6prom/web"
 )
 
 func main() {
@@ -30,50 +31,104 @@ func main() {
 	}
 	logger := zerolog.New(writer).Level(zerolog.Level(logLevel)).With().Timestamp().Str("name", "R6Prom").Logger()
 
+	if ok, err := runCommand(os.Args[1:], &logger); ok {
+		if err != nil {
+			logger.Fatal().Err(err).Msgf("%s failed", os.Args[1])
+		}
+		return
+	}
+
 	logger.Info().Str("version", constants.VERSION).Stringer("log_level", logger.GetLevel()).Msgf("setting up %s", constants.NAME)
 
 	conf, err := config.Load()
 	if err != nil {
 		logger.Fatal().Err(err).Msg("error setting up")
 	}
+	if conf.DryRun != "" {
+		// keep stdout free for the printed points
+		writer.Out = os.Stderr
+		logger = logger.Output(writer)
+	}
 
 	// create API instance
 	r6Logger := logger.With().Str("name", "R6API").Logger()
 	a := r6api.NewR6API(conf.Email, conf.Password, r6Logger)
 
-	influxClient := influxdb2.NewClientWithOptions(
-		conf.InfluxURL,
-		conf.InfluxAuthToken,
-		influxdb2.DefaultOptions().
-			SetBatchSize(10000). // high batch size to allow for manual flushing
-			SetApplicationName(constants.NAME).
-			SetLogLevel(influxlog.ErrorLevel).
-			SetPrecision(time.Second),
-	)
-	health, err := influxClient.Health(context.Background())
+	rewriter := metrics.NewRewriter(conf.MeasurementPrefix, conf.MeasurementRenames, conf.TagRenames, conf.StaticTags)
+	pointSink, closeSinks, err := setupSinks(conf, rewriter, &logger)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("could not get InfluxDB health")
+		logger.Fatal().Err(err).Msg("error setting up sinks")
 	}
-	if health.Status != domain.HealthCheckStatusPass {
-		logger.Fatal().Msg("InfluxDB server unhealthy, aborting")
+	defer closeSinks()
+
+	tabStats := metrics.NewTabStatsClient(conf.TabStatsBaseURL, conf.TabStatsTimeout)
+	rankedCollector := metrics.NewRankedCollector(conf.RankedHistoryDepth)
+	rankedProviders, err := metrics.NewRankedProviders(conf.RankedProviders, tabStats, rankedCollector)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error setting up ranked providers")
+	}
+
+	var snapshots *snapshot.DB
+	// a dry run saves no state, including snapshots
+	if conf.SnapshotDB != "" && conf.DryRun == "" {
+		if err := os.MkdirAll(filepath.Dir(conf.SnapshotDB), 0o755); err != nil {
+			logger.Fatal().Err(err).Msg("could not create snapshot directory")
+		}
+		snapshots, err = snapshot.Open(conf.SnapshotDB, conf.SnapshotRetention)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("could not open snapshot database")
+		}
+		defer snapshots.Close()
+		logger.Info().Str("path", conf.SnapshotDB).Msg("opened snapshot database")
+	}
+
+	var normalizer *enrich.Normalizer
+	if conf.Normalization {
+		normalizer, err = enrich.NewNormalizer(conf.NormalizeAliasesFile, conf.EnrichOperatorsFile, conf.EnrichMapsFile, func(tag string, name string) {
+			logger.Warn().Str("tag", tag).Str("name", name).Msg("unknown name, consider adding an alias")
+		})
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error loading normalization data")
+		}
+	}
+	var enricher *enrich.Enricher
+	if conf.Enrichment {
+		enricher, err = enrich.New(conf.EnrichOperatorsFile, conf.EnrichMapsFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error loading enrichment data")
+		}
 	}
-	logger.Info().Str("version", *health.Version).Str("msg", *health.Message).Str("db_name", health.Name).Msg("connected to InfluxDB")
-	writeAPI := influxClient.WriteAPI(conf.InfluxOrg, conf.InfluxBucket)
-	influxErrs := writeAPI.Errors()
-	defer influxClient.Close()
 
 	// create store
 	storeOpts := store.Opts{
-		ObservedUsernames: conf.ObservedUsernames,
-		InfluxWriteAPI:    writeAPI,
-		RefreshCron:       conf.RefreshCron,
+		ObservedUsernames:    conf.ObservedUsernames,
+		Sink:                 pointSink,
+		RefreshCron:          conf.RefreshCron,
+		FieldFilter:          metrics.NewFieldFilter(conf.FieldsAllow, conf.FieldsDeny),
+		Rewriter:             rewriter,
+		UserGroups:           conf.UserGroups,
+		LeaderboardMinRounds: conf.LeaderboardMinRounds,
+		TabStatsClient:       tabStats,
+		RankedCollector:      rankedCollector,
+		RankedReconciler:     metrics.NewRankedReconciler(rankedProviders, conf.RankedDiscrepancyThreshold),
+		StateDir:             conf.StateDir,
+		ReadOnlyState:        conf.DryRun != "",
+		SessionIdleRuns:      conf.SessionIdleRuns,
+		StreakEventLength:    conf.StreakEventLength,
+		Snapshots:            snapshots,
+		Normalizer:           normalizer,
+		Enricher:             enricher,
 	}
 	store, err := store.New(a, &logger, storeOpts)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("error creating store")
 	}
 	store.RunAsync()
-	for err := range influxErrs {
-		logger.Err(err).Msg("encountered Influx write error")
+	if conf.HTTPAddr != "" {
+		webLogger := logger.With().Str("name", "Web").Logger()
+		web.New(conf.HTTPAddr, store, conf.WebUI, &webLogger).RunAsync()
+	}
+	for err := range pointSink.Errors() {
+		logger.Err(err).Msg("encountered write error")
 	}
 }
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// AggregatedMeasurements lists the measurements that can be combined across users.
+var AggregatedMeasurements = map[string]bool{
+	"maps":      true,
+	"bombsites": true,
+	"actions":   true,
+	"matches":   true,
+}
+
+// derivedFields are recomputed after aggregation instead of being combined.
+var derivedFields = map[string]bool{
+	"kd_ratio":           true,
+	"win_rate":           true,
+	"round_win_rate":     true,
+	"entry_success_rate": true,
+	"headshot_rate":      true,
+	"kost_rate":          true,
+	"survival_rate":      true,
+	"clutch_rate":        true,
+}
+
+// weightField returns the field by which a non-additive field needs to be weighted when aggregating.
+// An empty string means the field is a plain counter and can be summed.
+//
+// rounds_with_* and rounds_survived are fractions of rounds played like the rates derived from them
+// (see roundRateFields), so they are averaged weighted by rounds_played instead of being summed.
+func weightField(field string) string {
+	switch {
+	case field == "headshot_percentage":
+		return "kills"
+	case strings.HasSuffix(field, "_per_match"):
+		return "matches_played"
+	case strings.HasSuffix(field, "_per_round"),
+		strings.HasPrefix(field, "rounds_with_"),
+		field == "rounds_survived":
+		return "rounds_played"
+	default:
+		return ""
+	}
+}
+
+// AggregateFields combines the fields of multiple users for the same series.
+// Counters are summed, averages and rates are weighted by the number of rounds or matches they refer to
+// and derived ratios are recomputed from the summed counters.
+func AggregateFields(fieldSets []map[string]interface{}) map[string]interface{} {
+	sums := map[string]float64{}
+	weights := map[string]float64{}
+	allInts := map[string]bool{}
+
+	for _, fields := range fieldSets {
+		for key, value := range fields {
+			if derivedFields[key] {
+				continue
+			}
+			v, ok := numeric(value)
+			if !ok {
+				continue
+			}
+			if _, seen := allInts[key]; !seen {
+				allInts[key] = true
+			}
+			switch value.(type) {
+			case float32, float64:
+				allInts[key] = false
+			}
+
+			wf := weightField(key)
+			if wf == "" {
+				sums[key] += v
+				continue
+			}
+			w, ok := numeric(fields[wf])
+			if !ok && wf == "matches_played" {
+				w, ok = numeric(fields["rounds_played"])
+			}
+			if !ok {
+				w = 1
+			}
+			sums[key] += v * w
+			weights[key] += w
+		}
+	}
+
+	result := make(map[string]interface{}, len(sums))
+	for key, sum := range sums {
+		if _, weighted := weights[key]; weighted {
+			if weights[key] > 0 {
+				result[key] = sum / weights[key]
+			}
+			continue
+		}
+		if allInts[key] {
+			result[key] = int64(sum)
+		} else {
+			result[key] = sum
+		}
+	}
+	AddDerivedFields(result)
+	return result
+}
+
+// AggregatePoints combines points of multiple users into one point per series.
+// Series are identified by measurement and all tags except username.
+// The resulting points carry a group tag and a members field with the number of combined points.
+func AggregatePoints(group string, points []*write.Point) []*write.Point {
+	type series struct {
+		measurement string
+		tags        map[string]string
+		fieldSets   []map[string]interface{}
+	}
+	allSeries := map[string]*series{}
+	keys := []string{}
+
+	for _, p := range points {
+		if !AggregatedMeasurements[p.Name()] {
+			continue
+		}
+		tags := map[string]string{}
+		var key strings.Builder
+		key.WriteString(p.Name())
+		for _, tag := range p.TagList() {
+			if tag.Key == "username" {
+				continue
+			}
+			tags[tag.Key] = tag.Value
+			key.WriteString("," + tag.Key + "=" + tag.Value)
+		}
+		fields := PointFields(p)
+
+		s, ok := allSeries[key.String()]
+		if !ok {
+			tags["group"] = group
+			s = &series{measurement: p.Name(), tags: tags}
+			allSeries[key.String()] = s
+			keys = append(keys, key.String())
+		}
+		s.fieldSets = append(s.fieldSets, fields)
+	}
+
+	sort.Strings(keys)
+	result := make([]*write.Point, 0, len(keys))
+	for _, key := range keys {
+		s := allSeries[key]
+		fields := AggregateFields(s.fieldSets)
+		fields["members"] = len(s.fieldSets)
+		result = append(result, influxdb2.NewPoint(s.measurement, s.tags, fields, points[0].Time()))
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// approxEqual compares field maps, allowing for rounding errors in float values.
+func approxEqual(got, want map[string]interface{}) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			return false
+		}
+		wf, wIsFloat := w.(float64)
+		gf, gIsFloat := g.(float64)
+		if wIsFloat != gIsFloat {
+			return false
+		}
+		if wIsFloat {
+			if math.Abs(wf-gf) > 1e-9 {
+				return false
+			}
+		} else if !reflect.DeepEqual(g, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWeightField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"kills", ""},
+		{"matches_played", ""},
+		{"headshot_percentage", "kills"},
+		{"kills_per_match", "matches_played"},
+		{"kills_per_round", "rounds_played"},
+		{"rounds_with_kost", "rounds_played"},
+		{"rounds_survived", "rounds_played"},
+		{"rounds_played", ""},
+	}
+	for _, tt := range tests {
+		if got := weightField(tt.field); got != tt.want {
+			t.Errorf("weightField(%q) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestAggregateFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldSets []map[string]interface{}
+		want      map[string]interface{}
+	}{
+		{
+			name: "counters are summed",
+			fieldSets: []map[string]interface{}{
+				{"kills": int64(3), "time_played": 1.5},
+				{"kills": int64(5), "time_played": 2.0},
+			},
+			want: map[string]interface{}{"kills": int64(8), "time_played": 3.5},
+		},
+		{
+			name: "headshot percentage weighted by kills",
+			fieldSets: []map[string]interface{}{
+				{"kills": int64(2), "headshot_percentage": 0.5},
+				{"kills": int64(8), "headshot_percentage": 0.2},
+			},
+			want: map[string]interface{}{"kills": int64(10), "headshot_percentage": 0.26},
+		},
+		{
+			name: "per match weighted by matches",
+			fieldSets: []map[string]interface{}{
+				{"matches_played": int64(1), "kills_per_match": 4.0},
+				{"matches_played": int64(3), "kills_per_match": 8.0},
+			},
+			want: map[string]interface{}{"matches_played": int64(4), "kills_per_match": 7.0},
+		},
+		{
+			name: "per match falls back to rounds",
+			fieldSets: []map[string]interface{}{
+				{"rounds_played": int64(10), "kills_per_match": 4.0},
+				{"rounds_played": int64(30), "kills_per_match": 8.0},
+			},
+			want: map[string]interface{}{"rounds_played": int64(40), "kills_per_match": 7.0},
+		},
+		{
+			name: "unweighted without weight field",
+			fieldSets: []map[string]interface{}{
+				{"kills_per_round": 1.0},
+				{"kills_per_round": 0.5},
+			},
+			want: map[string]interface{}{"kills_per_round": 0.75},
+		},
+		{
+			name: "round fractions weighted by rounds and rates recomputed",
+			fieldSets: []map[string]interface{}{
+				{"rounds_played": int64(10), "rounds_with_kost": 0.5, "kost_rate": 0.5},
+				{"rounds_played": int64(30), "rounds_with_kost": 0.8, "kost_rate": 0.8},
+			},
+			want: map[string]interface{}{"rounds_played": int64(40), "rounds_with_kost": 0.725, "kost_rate": 0.725},
+		},
+		{
+			name: "round fractions stay fractions",
+			fieldSets: []map[string]interface{}{
+				{"rounds_played": int64(20), "rounds_survived": 0.5, "rounds_with_clutch": 0.05, "survival_rate": 0.5, "clutch_rate": 0.05},
+				{"rounds_played": int64(20), "rounds_survived": 0.3, "rounds_with_clutch": 0.0, "survival_rate": 0.3, "clutch_rate": 0.0},
+			},
+			want: map[string]interface{}{"rounds_played": int64(40), "rounds_survived": 0.4, "rounds_with_clutch": 0.025, "survival_rate": 0.4, "clutch_rate": 0.025},
+		},
+		{
+			name: "zero weights omit field",
+			fieldSets: []map[string]interface{}{
+				{"rounds_played": int64(0), "rounds_survived": 0.5},
+			},
+			want: map[string]interface{}{"rounds_played": int64(0)},
+		},
+		{
+			name: "derived ratios recomputed from sums",
+			fieldSets: []map[string]interface{}{
+				{"kills": int64(4), "deaths": int64(1), "kd_ratio": 4.0},
+				{"kills": int64(2), "deaths": int64(5), "kd_ratio": 0.4},
+			},
+			want: map[string]interface{}{"kills": int64(6), "deaths": int64(6), "kd_ratio": 1.0},
+		},
+		{
+			name: "non-numeric fields ignored",
+			fieldSets: []map[string]interface{}{
+				{"kills": int64(1), "name": "x"},
+			},
+			want: map[string]interface{}{"kills": int64(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AggregateFields(tt.fieldSets); !approxEqual(got, tt.want) {
+				t.Errorf("AggregateFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregatePoints(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	points := []*write.Point{
+		influxdb2.NewPoint("maps", map[string]string{"username": "a", "map": "Oregon"}, map[string]interface{}{"kills": 1}, ts),
+		influxdb2.NewPoint("maps", map[string]string{"username": "b", "map": "Oregon"}, map[string]interface{}{"kills": 2}, ts),
+		influxdb2.NewPoint("maps", map[string]string{"username": "b", "map": "Villa"}, map[string]interface{}{"kills": 3}, ts),
+		influxdb2.NewPoint("ranked", map[string]string{"username": "a"}, map[string]interface{}{"mmr": 3000}, ts),
+	}
+
+	got := AggregatePoints("team", points)
+	if len(got) != 2 {
+		t.Fatalf("got %d points, want 2", len(got))
+	}
+	want := []struct {
+		tags   map[string]string
+		fields map[string]interface{}
+	}{
+		{map[string]string{"group": "team", "map": "Oregon"}, map[string]interface{}{"kills": int64(3), "members": int64(2)}},
+		{map[string]string{"group": "team", "map": "Villa"}, map[string]interface{}{"kills": int64(3), "members": int64(1)}},
+	}
+	for i, p := range got {
+		if p.Name() != "maps" || !p.Time().Equal(ts) {
+			t.Errorf("point %d: name %q, time %v", i, p.Name(), p.Time())
+		}
+		if !reflect.DeepEqual(PointTags(p), want[i].tags) {
+			t.Errorf("point %d: tags = %v, want %v", i, PointTags(p), want[i].tags)
+		}
+		if !reflect.DeepEqual(PointFields(p), want[i].fields) {
+			t.Errorf("point %d: fields = %v, want %v", i, PointFields(p), want[i].fields)
+		}
+	}
+}
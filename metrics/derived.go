@@ -0,0 +1,98 @@
+package metrics
+
+// numeric converts an Influx field value to float64.
+func numeric(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// roundRateFields maps derived rate fields to their source fields.
+//
+// The API reports the source fields as fraction of rounds played (roundsWithKOST, roundsSurvived
+// and roundsWithClutch, declared as float64 next to the integer round counters), not as round counts.
+// The rates are therefore the source values: kost_rate = rounds_with_kost, survival_rate = rounds_survived
+// and clutch_rate = rounds_with_clutch, written under the same names as all other rates.
+var roundRateFields = map[string]string{
+	"kost_rate":     "rounds_with_kost",
+	"survival_rate": "rounds_survived",
+	"clutch_rate":   "rounds_with_clutch",
+}
+
+// AddDerivedFields adds ratio fields computed from the raw counters in fields.
+// All rates are fractions between 0 and 1, not percentages.
+//
+// A derived field is only added if all of its source fields are present.
+// If the denominator is zero, the field is omitted instead of being written as 0.
+func AddDerivedFields(fields map[string]interface{}) {
+	get := func(key string) (float64, bool) {
+		v, ok := fields[key]
+		if !ok {
+			return 0, false
+		}
+		return numeric(v)
+	}
+	ratio := func(target string, numeratorKey string, denominatorKey string) {
+		numerator, ok := get(numeratorKey)
+		if !ok {
+			return
+		}
+		denominator, ok := get(denominatorKey)
+		if !ok || denominator == 0 {
+			return
+		}
+		fields[target] = numerator / denominator
+	}
+
+	ratio("kd_ratio", "kills", "deaths")
+	ratio("win_rate", "matches_won", "matches_played")
+	ratio("round_win_rate", "rounds_won", "rounds_played")
+	ratio("headshot_rate", "headshots", "kills")
+
+	if entryKills, ok := get("entry_kills"); ok {
+		if entryDeaths, ok := get("entry_deaths"); ok && entryKills+entryDeaths > 0 {
+			fields["entry_success_rate"] = entryKills / (entryKills + entryDeaths)
+		}
+	}
+
+	// like the other rates, round rates are omitted without rounds played
+	if roundsPlayed, ok := get("rounds_played"); ok && roundsPlayed > 0 {
+		for target, source := range roundRateFields {
+			if v, ok := get(source); ok {
+				fields[target] = v
+			}
+		}
+	}
+}
+
+// statFields converts a stats struct into fields including derived ratios.
+func statFields(v interface{}) map[string]interface{} {
+	fields := structFields(v)
+	AddDerivedFields(fields)
+	return fields
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddDerivedFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "kd ratio",
+			fields: map[string]interface{}{"kills": 6, "deaths": int64(4)},
+			want:   map[string]interface{}{"kd_ratio": 1.5},
+		},
+		{
+			name:   "kd ratio without deaths",
+			fields: map[string]interface{}{"kills": 6, "deaths": 0},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "missing source field",
+			fields: map[string]interface{}{"kills": 6},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "non-numeric source field",
+			fields: map[string]interface{}{"kills": "6", "deaths": 2},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "match and round rates",
+			fields: map[string]interface{}{"matches_won": 3, "matches_played": 4, "rounds_won": 10, "rounds_played": 20},
+			want:   map[string]interface{}{"win_rate": 0.75, "round_win_rate": 0.5},
+		},
+		{
+			name:   "headshot rate",
+			fields: map[string]interface{}{"headshots": 5, "kills": 20},
+			want:   map[string]interface{}{"headshot_rate": 0.25},
+		},
+		{
+			name:   "entry success rate",
+			fields: map[string]interface{}{"entry_kills": 3, "entry_deaths": 1},
+			want:   map[string]interface{}{"entry_success_rate": 0.75},
+		},
+		{
+			name:   "entry success rate without entries",
+			fields: map[string]interface{}{"entry_kills": 0, "entry_deaths": 0},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "round rates",
+			fields: map[string]interface{}{"rounds_played": 10, "rounds_with_kost": 0.6, "rounds_survived": 0.3, "rounds_with_clutch": 0.1},
+			want:   map[string]interface{}{"kost_rate": 0.6, "survival_rate": 0.3, "clutch_rate": 0.1},
+		},
+		{
+			name:   "round rates without rounds played field",
+			fields: map[string]interface{}{"rounds_with_kost": 0.6},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "round rates without rounds",
+			fields: map[string]interface{}{"rounds_played": 0, "rounds_with_kost": 0.5},
+			want:   map[string]interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := make(map[string]interface{}, len(tt.fields))
+			for k, v := range tt.fields {
+				fields[k] = v
+			}
+			AddDerivedFields(fields)
+
+			got := map[string]interface{}{}
+			for k, v := range fields {
+				if _, ok := tt.fields[k]; !ok {
+					got[k] = v
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("derived fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// fieldTag can be set on struct fields to override the generated field name.
+// A value of "-" excludes the struct field.
+const fieldTag = "field"
+
+// structFields converts all numeric and boolean fields of a stats struct into Influx fields.
+// Field names are derived from the struct field names in snake_case, embedded structs are flattened.
+// Nested, non-embedded structs, slices, maps and strings are skipped.
+func structFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	addStructFields(reflect.ValueOf(v), fields)
+	return fields
+}
+
+func addStructFields(rv reflect.Value, fields map[string]interface{}) {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		fv := rv.Field(i)
+		if f.Anonymous {
+			addStructFields(fv, fields)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Tag.Get(fieldTag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = toSnakeCase(f.Name)
+		}
+		switch fv.Kind() {
+		case reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			fields[name] = fv.Interface()
+		}
+	}
+}
+
+// toSnakeCase converts Go identifiers like "RoundsWithKOST" or "KOSTRate" to "rounds_with_kost" and "kost_rate".
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	b.Grow(len(s) + 4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// FieldFilter restricts the fields written for each measurement.
+// Entries are either plain field names ("kills"), which apply to all measurements,
+// or qualified with a measurement name ("maps.kills").
+type FieldFilter struct {
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+// NewFieldFilter creates a filter from allow and deny lists.
+// If allow is empty, all fields not in deny are written.
+func NewFieldFilter(allow []string, deny []string) *FieldFilter {
+	return &FieldFilter{
+		allow: toSet(allow),
+		deny:  toSet(deny),
+	}
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = struct{}{}
+		}
+	}
+	return set
+}
+
+func (f *FieldFilter) contains(set map[string]struct{}, measurement string, field string) bool {
+	if _, ok := set[field]; ok {
+		return true
+	}
+	_, ok := set[measurement+"."+field]
+	return ok
+}
+
+// Allowed reports whether the field should be written for the given measurement.
+// The schema version field is only removed if it is denied explicitly.
+func (f *FieldFilter) Allowed(measurement string, field string) bool {
+	if f == nil {
+		return true
+	}
+	if f.contains(f.deny, measurement, field) {
+		return false
+	}
+	return len(f.allow) == 0 || field == SchemaVersionField || f.contains(f.allow, measurement, field)
+}
+
+// Apply returns a copy of p containing only allowed fields.
+// If all fields are allowed, p is returned unchanged. If no field other than the schema version remains, nil is returned.
+func (f *FieldFilter) Apply(p *write.Point) *write.Point {
+	if f == nil || (len(f.allow) == 0 && len(f.deny) == 0) {
+		return p
+	}
+	fields := map[string]interface{}{}
+	for _, field := range p.FieldList() {
+		if f.Allowed(p.Name(), field.Key) {
+			fields[field.Key] = field.Value
+		}
+	}
+	if len(fields) == len(p.FieldList()) {
+		return p
+	}
+	if _, stamped := fields[SchemaVersionField]; len(fields) == 0 || (stamped && len(fields) == 1) {
+		return nil
+	}
+	return influxdb2.NewPoint(p.Name(), PointTags(p), fields, p.Time())
+}
+
+// ApplySchema returns the schema without the fields which are not allowed.
+func (f *FieldFilter) ApplySchema(s Schema) Schema {
+	if f == nil || (len(f.allow) == 0 && len(f.deny) == 0) {
+		return s
+	}
+	result := Schema{Version: s.Version, Measurements: make([]MeasurementSchema, len(s.Measurements))}
+	for i, m := range s.Measurements {
+		fields := make([]FieldSchema, 0, len(m.Fields))
+		for _, field := range m.Fields {
+			if f.Allowed(m.Name, field.Name) {
+				fields = append(fields, field)
+			}
+		}
+		m.Fields = fields
+		result.Measurements[i] = m
+	}
+	return result
+}
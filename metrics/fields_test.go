@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Kills", "kills"},
+		{"RoundsPlayed", "rounds_played"},
+		{"RoundsWithKOST", "rounds_with_kost"},
+		{"KOSTRate", "kost_rate"},
+		{"HeadshotPercentage", "headshot_percentage"},
+		{"Rounds2Won", "rounds2_won"},
+		{"MMR", "mmr"},
+	}
+	for _, tt := range tests {
+		if got := toSnakeCase(tt.in); got != tt.want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+type testEmbedded struct {
+	Deaths int
+}
+
+type testStats struct {
+	testEmbedded
+	Kills      int
+	KDRatio    float64
+	Renamed    int `field:"custom"`
+	Skipped    int `field:"-"`
+	Name       string
+	Nested     struct{ Value int }
+	Won        bool
+	unexported int
+}
+
+func TestStructFields(t *testing.T) {
+	s := testStats{testEmbedded: testEmbedded{Deaths: 2}, Kills: 3, KDRatio: 1.5, Renamed: 4, Skipped: 5, Name: "x", Won: true, unexported: 6}
+	want := map[string]interface{}{
+		"deaths":   2,
+		"kills":    3,
+		"kd_ratio": 1.5,
+		"custom":   4,
+		"won":      true,
+	}
+	if got := structFields(&s); !reflect.DeepEqual(got, want) {
+		t.Errorf("structFields() = %v, want %v", got, want)
+	}
+	if got := structFields((*testStats)(nil)); len(got) != 0 {
+		t.Errorf("structFields(nil) = %v, want no fields", got)
+	}
+}
+
+func TestFieldFilterAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow, deny []string
+		measurement string
+		field       string
+		want        bool
+	}{
+		{"no lists", nil, nil, "maps", "kills", true},
+		{"denied", nil, []string{"kills"}, "maps", "kills", false},
+		{"denied other field", nil, []string{"kills"}, "maps", "deaths", true},
+		{"denied qualified", nil, []string{"maps.kills"}, "maps", "kills", false},
+		{"denied qualified other measurement", nil, []string{"maps.kills"}, "actions", "kills", true},
+		{"allowed", []string{"kills"}, nil, "maps", "kills", true},
+		{"not allowed", []string{"kills"}, nil, "maps", "deaths", false},
+		{"allowed qualified", []string{"maps.deaths"}, nil, "maps", "deaths", true},
+		{"allowed qualified other measurement", []string{"maps.deaths"}, nil, "actions", "deaths", false},
+		{"deny wins", []string{"kills"}, []string{"maps.kills"}, "maps", "kills", false},
+		{"whitespace trimmed", []string{" kills "}, nil, "maps", "kills", true},
+		{"schema version not in allow list", []string{"kills"}, nil, "maps", SchemaVersionField, true},
+		{"schema version denied", []string{"kills"}, []string{SchemaVersionField}, "maps", SchemaVersionField, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFieldFilter(tt.allow, tt.deny)
+			if got := f.Allowed(tt.measurement, tt.field); got != tt.want {
+				t.Errorf("Allowed(%q, %q) = %v, want %v", tt.measurement, tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldFilterApply(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	p := influxdb2.NewPoint("maps", map[string]string{"map": "Oregon"}, map[string]interface{}{"kills": 1, "deaths": 2}, ts)
+
+	var nilFilter *FieldFilter
+	if got := nilFilter.Apply(p); got != p {
+		t.Error("nil filter should return the point unchanged")
+	}
+	if got := NewFieldFilter(nil, []string{"assists"}).Apply(p); got != p {
+		t.Error("filter keeping all fields should return the point unchanged")
+	}
+	if got := NewFieldFilter(nil, []string{"kills", "deaths"}).Apply(p); got != nil {
+		t.Errorf("filter removing all fields should return nil, got %v", got)
+	}
+
+	stamped := StampSchemaVersion(p)
+	if got := NewFieldFilter(nil, []string{"kills", "deaths"}).Apply(stamped); got != nil {
+		t.Errorf("filter removing all fields but the schema version should return nil, got %v", got)
+	}
+	if got := NewFieldFilter(nil, []string{SchemaVersionField}).Apply(stamped); !reflect.DeepEqual(PointFields(got), PointFields(p)) {
+		t.Errorf("filter denying the schema version returned %v", got)
+	}
+
+	got := NewFieldFilter(nil, []string{"deaths"}).Apply(p)
+	if got == nil {
+		t.Fatal("expected point")
+	}
+	if want := map[string]interface{}{"kills": int64(1)}; !reflect.DeepEqual(PointFields(got), want) {
+		t.Errorf("fields = %v, want %v", PointFields(got), want)
+	}
+	if !reflect.DeepEqual(PointTags(got), PointTags(p)) || !got.Time().Equal(ts) || got.Name() != "maps" {
+		t.Errorf("filtered point changed name, tags or time: %v", got)
+	}
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// Leaderboard scopes
+const (
+	ScopeOverall  = "overall"
+	ScopeMap      = "map"
+	ScopeOperator = "operator"
+)
+
+// Leaderboard holds the rankings among observed users computed after a run.
+type Leaderboard struct {
+	Time   time.Time `json:"time"`
+	Boards []*Board  `json:"boards"`
+}
+
+// Board ranks users in a single category, e.g. K/D on a specific map.
+type Board struct {
+	SeasonSlug string     `json:"season_slug"`
+	Gamemode   string     `json:"gamemode"`
+	Category   string     `json:"category"`
+	Scope      string     `json:"scope"`
+	Subject    string     `json:"subject"`
+	Rankings   []*Ranking `json:"rankings"`
+}
+
+// Ranking is the position of a single user on a board.
+type Ranking struct {
+	Rank         int     `json:"rank"`
+	Username     string  `json:"username"`
+	Value        float64 `json:"value"`
+	RoundsPlayed int64   `json:"rounds_played,omitempty"`
+}
+
+type boardKey struct {
+	seasonSlug string
+	gamemode   string
+	category   string
+	scope      string
+	subject    string
+}
+
+type leaderboardBuilder struct {
+	minRounds int64
+	boards    map[boardKey]*Board
+}
+
+func (b *leaderboardBuilder) add(key boardKey, username string, fields map[string]interface{}) {
+	value, ok := numeric(fields[key.category])
+	if !ok {
+		return
+	}
+	rounds, hasRounds := numeric(fields["rounds_played"])
+	if hasRounds && int64(rounds) < b.minRounds {
+		return
+	}
+	board, ok := b.boards[key]
+	if !ok {
+		board = &Board{
+			SeasonSlug: key.seasonSlug,
+			Gamemode:   key.gamemode,
+			Category:   key.category,
+			Scope:      key.scope,
+			Subject:    key.subject,
+		}
+		b.boards[key] = board
+	}
+	board.Rankings = append(board.Rankings, &Ranking{
+		Username:     username,
+		Value:        value,
+		RoundsPlayed: int64(rounds),
+	})
+}
+
+// BuildLeaderboard ranks users by MMR, K/D and win rate overall, per map and per operator.
+// points contains all points of a run per user. Users with fewer than minRounds rounds played
+// in a category are excluded from it.
+//
+// The overall boards use the totals of the matches measurement, as the stats per map don't necessarily
+// add up to them. Categories missing from the totals, like K/D if the summary has no kills and deaths, have no overall board.
+func BuildLeaderboard(points map[string][]*write.Point, minRounds int, t time.Time) *Leaderboard {
+	b := &leaderboardBuilder{
+		minRounds: int64(minRounds),
+		boards:    map[boardKey]*Board{},
+	}
+
+	for _, userPoints := range points {
+		for _, p := range userPoints {
+			tags := PointTags(p)
+			fields := PointFields(p)
+			username := tags["username"]
+
+			switch p.Name() {
+			case "ranked":
+				b.add(boardKey{tags["season_slug"], "ranked", "mmr", ScopeOverall, "all"}, username, fields)
+			case "matches":
+				for _, category := range []string{"kd_ratio", "win_rate"} {
+					b.add(boardKey{tags["season_slug"], tags["gamemode"], category, ScopeOverall, "all"}, username, fields)
+				}
+			case "maps":
+				for _, category := range []string{"kd_ratio", "win_rate"} {
+					b.add(boardKey{tags["season_slug"], tags["gamemode"], category, ScopeMap, tags["map"]}, username, fields)
+				}
+			case "actions":
+				if tags["role"] != "all" {
+					continue
+				}
+				for _, category := range []string{"kd_ratio", "round_win_rate"} {
+					b.add(boardKey{tags["season_slug"], tags["gamemode"], category, ScopeOperator, tags["operator"]}, username, fields)
+				}
+			}
+		}
+	}
+
+	lb := &Leaderboard{Time: t, Boards: make([]*Board, 0, len(b.boards))}
+	for _, board := range b.boards {
+		rankBoard(board)
+		lb.Boards = append(lb.Boards, board)
+	}
+	sort.Slice(lb.Boards, func(i, j int) bool {
+		bi, bj := lb.Boards[i], lb.Boards[j]
+		if bi.SeasonSlug != bj.SeasonSlug {
+			return bi.SeasonSlug < bj.SeasonSlug
+		}
+		if bi.Gamemode != bj.Gamemode {
+			return bi.Gamemode < bj.Gamemode
+		}
+		if bi.Scope != bj.Scope {
+			return bi.Scope < bj.Scope
+		}
+		if bi.Subject != bj.Subject {
+			return bi.Subject < bj.Subject
+		}
+		return bi.Category < bj.Category
+	})
+	return lb
+}
+
+// rankBoard sorts rankings by value descending. Equal values share the same rank.
+func rankBoard(board *Board) {
+	sort.Slice(board.Rankings, func(i, j int) bool {
+		ri, rj := board.Rankings[i], board.Rankings[j]
+		if ri.Value != rj.Value {
+			return ri.Value > rj.Value
+		}
+		return ri.Username < rj.Username
+	})
+	for i, r := range board.Rankings {
+		if i > 0 && r.Value == board.Rankings[i-1].Value {
+			r.Rank = board.Rankings[i-1].Rank
+		} else {
+			r.Rank = i + 1
+		}
+	}
+}
+
+// Points converts the leaderboard into one leaderboard point per user and board.
+func (lb *Leaderboard) Points() []*write.Point {
+	var points []*write.Point
+	for _, board := range lb.Boards {
+		for _, r := range board.Rankings {
+			fields := map[string]interface{}{
+				"rank":         r.Rank,
+				"value":        r.Value,
+				"participants": len(board.Rankings),
+			}
+			if r.RoundsPlayed > 0 {
+				fields["rounds_played"] = r.RoundsPlayed
+			}
+			points = append(points, influxdb2.NewPoint(
+				"leaderboard",
+				map[string]string{
+					"season_slug": board.SeasonSlug,
+					"gamemode":    board.Gamemode,
+					"category":    board.Category,
+					"scope":       board.Scope,
+					"subject":     board.Subject,
+					"username":    r.Username,
+				},
+				fields,
+				lb.Time,
+			))
+		}
+	}
+	return points
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+func TestRankBoard(t *testing.T) {
+	board := &Board{Rankings: []*Ranking{
+		{Username: "carol", Value: 1.0},
+		{Username: "bob", Value: 2.0},
+		{Username: "dave", Value: 0.5},
+		{Username: "alice", Value: 2.0},
+	}}
+	rankBoard(board)
+
+	type rank struct {
+		username string
+		rank     int
+	}
+	var got []rank
+	for _, r := range board.Rankings {
+		got = append(got, rank{r.Username, r.Rank})
+	}
+	// equal values share a rank and are ordered by name, the next rank skips the shared ones
+	want := []rank{{"alice", 1}, {"bob", 1}, {"carol", 3}, {"dave", 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rankings = %v, want %v", got, want)
+	}
+}
+
+func leaderboardPoint(measurement string, tags map[string]string, fields map[string]interface{}) *write.Point {
+	return influxdb2.NewPoint(measurement, tags, fields, time.Unix(1700000000, 0))
+}
+
+func TestBuildLeaderboard(t *testing.T) {
+	points := map[string][]*write.Point{
+		"alice": {
+			leaderboardPoint("ranked", map[string]string{"username": "alice", "season_slug": "Y8S4"}, map[string]interface{}{"mmr": 3000}),
+			leaderboardPoint("ranked", map[string]string{"username": "alice", "season_slug": "Y8S3"}, map[string]interface{}{"mmr": 2000}),
+			leaderboardPoint("matches", map[string]string{"username": "alice", "season_slug": "Y8S4", "gamemode": "ranked"}, map[string]interface{}{"win_rate": 0.6, "kd_ratio": 1.2}),
+			// per map stats don't add up to the totals and are not used for the overall boards
+			leaderboardPoint("maps", map[string]string{"username": "alice", "season_slug": "Y8S4", "gamemode": "ranked", "map": "Oregon"}, map[string]interface{}{"win_rate": 1.0, "kd_ratio": 3.0, "rounds_played": 20}),
+		},
+		"bob": {
+			leaderboardPoint("ranked", map[string]string{"username": "bob", "season_slug": "Y8S4"}, map[string]interface{}{"mmr": 3000}),
+			leaderboardPoint("matches", map[string]string{"username": "bob", "season_slug": "Y8S4", "gamemode": "ranked"}, map[string]interface{}{"win_rate": 0.5}),
+			// too few rounds
+			leaderboardPoint("maps", map[string]string{"username": "bob", "season_slug": "Y8S4", "gamemode": "ranked", "map": "Oregon"}, map[string]interface{}{"win_rate": 0.5, "kd_ratio": 1.0, "rounds_played": 5}),
+		},
+		// users without points are missing from all boards
+		"carol": nil,
+	}
+
+	lb := BuildLeaderboard(points, 10, time.Unix(1700000000, 0))
+
+	type board struct {
+		season, gamemode, category, scope, subject string
+		rankings                                   string
+	}
+	var got []board
+	for _, b := range lb.Boards {
+		rankings := ""
+		for _, r := range b.Rankings {
+			rankings += fmt.Sprintf("%s:%d ", r.Username, r.Rank)
+		}
+		got = append(got, board{b.SeasonSlug, b.Gamemode, b.Category, b.Scope, b.Subject, rankings})
+	}
+	want := []board{
+		{"Y8S3", "ranked", "mmr", ScopeOverall, "all", "alice:1 "},
+		{"Y8S4", "ranked", "kd_ratio", ScopeMap, "Oregon", "alice:1 "},
+		{"Y8S4", "ranked", "win_rate", ScopeMap, "Oregon", "alice:1 "},
+		{"Y8S4", "ranked", "kd_ratio", ScopeOverall, "all", "alice:1 "},
+		{"Y8S4", "ranked", "mmr", ScopeOverall, "all", "alice:1 bob:1 "},
+		{"Y8S4", "ranked", "win_rate", ScopeOverall, "all", "alice:1 bob:2 "},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("boards =\n%v\nwant\n%v", got, want)
+	}
+
+	var overallWinRate float64
+	for _, b := range lb.Boards {
+		if b.Scope == ScopeOverall && b.Category == "win_rate" {
+			overallWinRate = b.Rankings[0].Value
+		}
+	}
+	if overallWinRate != 0.6 {
+		t.Errorf("overall win rate = %v, want the total of the matches measurement", overallWinRate)
+	}
+}
@@ -9,10 +9,9 @@ import (
 	"github.com/stnokott/r6api/types/stats"
 )
 
-func SendMapStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, t time.Time, chData chan<- StatResponse) {
-	currentSeason := meta.Seasons[len(meta.Seasons)-1]
+func SendMapStats(api *r6api.R6API, profile *r6api.Profile, _ *metadata.Metadata, season Season, t time.Time, chData chan<- StatResponse) {
 	mapStats := new(stats.MapStats)
-	if err := api.GetStats(profile, currentSeason.Slug, mapStats); err != nil {
+	if err := api.GetStats(profile, season.Slug, mapStats); err != nil {
 		chData <- StatResponse{Err: err}
 		return
 	}
@@ -30,8 +29,8 @@ func SendMapStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metad
 		}
 		for mapName, mapStats := range *gameModeStats {
 			labels := map[string]string{
-				"season_slug": currentSeason.Slug,
-				"season_name": currentSeason.Name,
+				"season_slug": season.Slug,
+				"season_name": season.Name,
 				"username":    profile.Name,
 				"gamemode":    gameModeName,
 				"map":         mapName,
@@ -40,41 +39,7 @@ func SendMapStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metad
 				P: influxdb2.NewPoint(
 					"maps",
 					labels,
-					map[string]interface{}{
-						"matches_played":          mapStats.MatchesPlayed,
-						"matches_won":             mapStats.MatchesWon,
-						"matches_lost":            mapStats.MatchesLost,
-						"kills":                   mapStats.Kills,
-						"deaths":                  mapStats.Deaths,
-						"assists":                 mapStats.Assists,
-						"melee_kills":             mapStats.MeleeKills,
-						"team_kills":              mapStats.TeamKills,
-						"trades":                  mapStats.Trades,
-						"revives":                 mapStats.Revives,
-						"headshots":               mapStats.Headshots,
-						"rounds_played":           mapStats.RoundsPlayed,
-						"rounds_won":              mapStats.RoundsWon,
-						"rounds_lost":             mapStats.RoundsLost,
-						"minutes_played":          mapStats.MinutesPlayed,
-						"kills_per_round":         mapStats.KillsPerRound,
-						"headshot_percentage":     mapStats.HeadshotPercentage,
-						"entry_deaths":            mapStats.EntryDeaths,
-						"entry_death_trades":      mapStats.EntryDeathTrades,
-						"entry_kills":             mapStats.EntryKills,
-						"entry_kill_trades":       mapStats.EntryKillTrades,
-						"rounds_survived":         mapStats.RoundsSurvived,
-						"rounds_with_kill":        mapStats.RoundsWithKill,
-						"rounds_with_multikill":   mapStats.RoundsWithMultikill,
-						"rounds_with_ace":         mapStats.RoundsWithAce,
-						"rounds_with_clutch":      mapStats.RoundsWithClutch,
-						"rounds_with_kost":        mapStats.RoundsWithKOST,
-						"rounds_with_entry_death": mapStats.RoundsWithEntryDeath,
-						"rounds_with_entry_kill":  mapStats.RoundsWithEntryKill,
-						"distance_per_round":      mapStats.DistancePerRound,
-						"distance_total":          mapStats.DistanceTotal,
-						"time_alive_per_match":    mapStats.TimeAlivePerMatch,
-						"time_dead_per_match":     mapStats.TimeDeadPerMatch,
-					},
+					statFields(mapStats),
 					t,
 				),
 			}
@@ -83,6 +48,7 @@ func SendMapStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metad
 			}
 		}
 	}
+	chData <- StatResponse{Raw: &RawStats{Collector: "maps", Measurements: []string{"maps", "bombsites"}, Data: mapStats}}
 	chData <- StatResponse{Done: true}
 }
 
@@ -106,38 +72,7 @@ func sendMapBombsiteStats(s *stats.BombsiteGamemodeStats, chData chan<- StatResp
 				P: influxdb2.NewPoint(
 					"bombsites",
 					labels,
-					map[string]interface{}{
-						"kills":                   bombsiteStats.Kills,
-						"deaths":                  bombsiteStats.Deaths,
-						"assists":                 bombsiteStats.Assists,
-						"melee_kills":             bombsiteStats.MeleeKills,
-						"team_kills":              bombsiteStats.TeamKills,
-						"trades":                  bombsiteStats.Trades,
-						"revives":                 bombsiteStats.Revives,
-						"headshots":               bombsiteStats.Headshots,
-						"rounds_played":           bombsiteStats.RoundsPlayed,
-						"rounds_won":              bombsiteStats.RoundsWon,
-						"rounds_lost":             bombsiteStats.RoundsLost,
-						"minutes_played":          bombsiteStats.MinutesPlayed,
-						"kills_per_round":         bombsiteStats.KillsPerRound,
-						"headshot_percentage":     bombsiteStats.HeadshotPercentage,
-						"entry_deaths":            bombsiteStats.EntryDeaths,
-						"entry_death_trades":      bombsiteStats.EntryDeathTrades,
-						"entry_kills":             bombsiteStats.EntryKills,
-						"entry_kill_trades":       bombsiteStats.EntryKillTrades,
-						"rounds_survived":         bombsiteStats.RoundsSurvived,
-						"rounds_with_kill":        bombsiteStats.RoundsWithKill,
-						"rounds_with_multikill":   bombsiteStats.RoundsWithMultikill,
-						"rounds_with_ace":         bombsiteStats.RoundsWithAce,
-						"rounds_with_clutch":      bombsiteStats.RoundsWithClutch,
-						"rounds_with_kost":        bombsiteStats.RoundsWithKOST,
-						"rounds_with_entry_death": bombsiteStats.RoundsWithEntryDeath,
-						"rounds_with_entry_kill":  bombsiteStats.RoundsWithEntryKill,
-						"distance_per_round":      bombsiteStats.DistancePerRound,
-						"distance_total":          bombsiteStats.DistanceTotal,
-						"time_alive_per_match":    bombsiteStats.TimeAlivePerMatch,
-						"time_dead_per_match":     bombsiteStats.TimeDeadPerMatch,
-					},
+					statFields(bombsiteStats),
 					t,
 				),
 			}
@@ -9,10 +9,9 @@ import (
 	"github.com/stnokott/r6api/types/stats"
 )
 
-func SendMatchStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, t time.Time, chData chan<- StatResponse) {
-	currentSeason := meta.Seasons[len(meta.Seasons)-1]
+func SendMatchStats(api *r6api.R6API, profile *r6api.Profile, _ *metadata.Metadata, season Season, t time.Time, chData chan<- StatResponse) {
 	summarizedStats := new(stats.SummarizedStats)
-	if err := api.GetStats(profile, currentSeason.Slug, summarizedStats); err != nil {
+	if err := api.GetStats(profile, season.Slug, summarizedStats); err != nil {
 		chData <- StatResponse{Err: err}
 		return
 	}
@@ -25,23 +24,23 @@ func SendMatchStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Met
 	}
 
 	for gameModeName, gameModeStats := range gameModes {
+		if gameModeStats == nil {
+			continue
+		}
 		chData <- StatResponse{
 			P: influxdb2.NewPoint(
 				"matches",
 				map[string]string{
-					"season_slug": currentSeason.Slug,
-					"season_name": currentSeason.Name,
+					"season_slug": season.Slug,
+					"season_name": season.Name,
 					"username":    profile.Name,
 					"gamemode":    gameModeName,
 				},
-				map[string]interface{}{
-					"matches_played": gameModeStats.MatchesPlayed,
-					"matches_won":    gameModeStats.MatchesWon,
-					"matches_lost":   gameModeStats.MatchesLost,
-				},
+				statFields(gameModeStats),
 				t,
 			),
 		}
 	}
+	chData <- StatResponse{Raw: &RawStats{Collector: "matches", Measurements: []string{"matches"}, Data: summarizedStats}}
 	chData <- StatResponse{Done: true}
 }
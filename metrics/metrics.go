@@ -10,16 +10,33 @@ import (
 
 type StatResponse struct {
 	P    *write.Point
+	Raw  *RawStats
 	Done bool
 	Err  error
+	// Warning is an error which is logged without ending the collector, e.g. of a provider with a fallback.
+	Warning error
 }
 
-type StatSenderFunc func(*r6api.R6API, *r6api.Profile, *metadata.Metadata, time.Time, chan<- StatResponse)
+// RawStats is the raw API response a collector derived its points from.
+type RawStats struct {
+	Collector string
+	// Measurements lists the measurements of the points derived from Data.
+	Measurements []string
+	Data         interface{}
+}
+
+// StatSenderFunc collects stats of a single profile for the given season and sends them to the channel.
+// It must send a response with Done or Err set once finished.
+type StatSenderFunc func(*r6api.R6API, *r6api.Profile, *metadata.Metadata, Season, time.Time, chan<- StatResponse)
 
-var AllSenders = []StatSenderFunc{
-	SendMapStats,
-	SendMatchStats,
-	SendOperatorStats,
-	SendRankedStats,
-	SendRankedTabStatsStats,
+// AllSenders returns all stat senders, using the given collectors for the ranked, ranked_tabstats and ranked_combined measurements.
+func AllSenders(ranked *RankedCollector, tabStats *TabStatsClient, reconciler *RankedReconciler) []StatSenderFunc {
+	return []StatSenderFunc{
+		SendMapStats,
+		SendMatchStats,
+		SendOperatorStats,
+		ranked.SendRankedStats,
+		tabStats.SendRankedStats,
+		reconciler.SendRankedStats,
+	}
 }
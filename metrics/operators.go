@@ -9,10 +9,9 @@ import (
 	"github.com/stnokott/r6api/types/stats"
 )
 
-func SendOperatorStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, t time.Time, chData chan<- StatResponse) {
-	currentSeason := meta.Seasons[len(meta.Seasons)-1]
+func SendOperatorStats(api *r6api.R6API, profile *r6api.Profile, _ *metadata.Metadata, season Season, t time.Time, chData chan<- StatResponse) {
 	operatorStats := new(stats.OperatorStats)
-	if err := api.GetStats(profile, currentSeason.Slug, operatorStats); err != nil {
+	if err := api.GetStats(profile, season.Slug, operatorStats); err != nil {
 		chData <- StatResponse{Err: err}
 		return
 	}
@@ -36,50 +35,20 @@ func SendOperatorStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.
 					P: influxdb2.NewPoint(
 						"actions",
 						map[string]string{
-							"season_slug": currentSeason.Slug,
-							"season_name": currentSeason.Name,
+							"season_slug": season.Slug,
+							"season_name": season.Name,
 							"username":    profile.Name,
 							"gamemode":    gameModeName,
 							"role":        roleName,
 							"operator":    operatorName,
 						},
-						map[string]interface{}{
-							"kills":                   operatorStats.Kills,
-							"deaths":                  operatorStats.Deaths,
-							"assists":                 operatorStats.Assists,
-							"melee_kills":             operatorStats.MeleeKills,
-							"team_kills":              operatorStats.TeamKills,
-							"trades":                  operatorStats.Trades,
-							"revives":                 operatorStats.Revives,
-							"headshots":               operatorStats.Headshots,
-							"rounds_played":           operatorStats.RoundsPlayed,
-							"rounds_won":              operatorStats.RoundsWon,
-							"rounds_lost":             operatorStats.RoundsLost,
-							"minutes_played":          operatorStats.MinutesPlayed,
-							"kills_per_round":         operatorStats.KillsPerRound,
-							"headshot_percentage":     operatorStats.HeadshotPercentage,
-							"entry_deaths":            operatorStats.EntryDeaths,
-							"entry_death_trades":      operatorStats.EntryDeathTrades,
-							"entry_kills":             operatorStats.EntryKills,
-							"entry_kill_trades":       operatorStats.EntryKillTrades,
-							"rounds_survived":         operatorStats.RoundsSurvived,
-							"rounds_with_kill":        operatorStats.RoundsWithKill,
-							"rounds_with_multikill":   operatorStats.RoundsWithMultikill,
-							"rounds_with_ace":         operatorStats.RoundsWithAce,
-							"rounds_with_clutch":      operatorStats.RoundsWithClutch,
-							"rounds_with_kost":        operatorStats.RoundsWithKOST,
-							"rounds_with_entry_death": operatorStats.RoundsWithEntryDeath,
-							"rounds_with_entry_kill":  operatorStats.RoundsWithEntryKill,
-							"distance_per_round":      operatorStats.DistancePerRound,
-							"distance_total":          operatorStats.DistanceTotal,
-							"time_alive_per_match":    operatorStats.TimeAlivePerMatch,
-							"time_dead_per_match":     operatorStats.TimeDeadPerMatch,
-						},
+						statFields(operatorStats),
 						t,
 					),
 				}
 			}
 		}
 	}
+	chData <- StatResponse{Raw: &RawStats{Collector: "operators", Measurements: []string{"actions"}, Data: operatorStats}}
 	chData <- StatResponse{Done: true}
 }
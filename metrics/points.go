@@ -0,0 +1,30 @@
+package metrics
+
+import "github.com/influxdata/influxdb-client-go/v2/api/write"
+
+// PointTags returns the tags of p as map.
+func PointTags(p *write.Point) map[string]string {
+	tags := make(map[string]string, len(p.TagList()))
+	for _, tag := range p.TagList() {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+// PointFields returns the fields of p as map.
+func PointFields(p *write.Point) map[string]interface{} {
+	fields := make(map[string]interface{}, len(p.FieldList()))
+	for _, field := range p.FieldList() {
+		fields[field.Key] = field.Value
+	}
+	return fields
+}
+
+// FloatField returns the numeric value of a field, false if it is missing or not numeric.
+func FloatField(fields map[string]interface{}, key string) (float64, bool) {
+	v, ok := fields[key]
+	if !ok {
+		return 0, false
+	}
+	return numeric(v)
+}
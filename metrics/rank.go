@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RankUnranked is the tier of players who have not finished their placement matches.
+const RankUnranked = "unranked"
+
+// rankTiers lists all ranked tiers from lowest to highest, champion has no divisions.
+var rankTiers = []string{"copper", "bronze", "silver", "gold", "platinum", "emerald", "diamond", "champion"}
+
+const (
+	divisionsPerTier = 5
+	championTier     = "champion"
+)
+
+var (
+	romanDivisions = map[string]int{"i": 1, "ii": 2, "iii": 3, "iv": 4, "v": 5}
+	romanNumerals  = []string{"", "I", "II", "III", "IV", "V"}
+)
+
+// Rank describes a ranked placement.
+type Rank struct {
+	// Tier is one of rankTiers or RankUnranked.
+	Tier string
+	// Division ranges from 5 (lowest) to 1 (highest), 0 for champion and unranked.
+	Division int
+}
+
+// Ordinal returns the rank as number, 0 for unranked, 1 for Copper V up to 36 for Champion.
+func (r Rank) Ordinal() int {
+	for i, tier := range rankTiers {
+		if tier != r.Tier {
+			continue
+		}
+		if tier == championTier {
+			return i*divisionsPerTier + 1
+		}
+		return i*divisionsPerTier + (divisionsPerTier - r.Division + 1)
+	}
+	return 0
+}
+
+// String returns a human-readable rank like "Gold II".
+func (r Rank) String() string {
+	if r.Tier == "" {
+		return ""
+	}
+	name := strings.ToUpper(r.Tier[:1]) + r.Tier[1:]
+	if r.Division < 1 || r.Division > divisionsPerTier {
+		return name
+	}
+	return name + " " + romanNumerals[r.Division]
+}
+
+// RankFromOrdinal is the inverse of Rank.Ordinal.
+func RankFromOrdinal(ordinal int) (Rank, error) {
+	if ordinal == 0 {
+		return Rank{Tier: RankUnranked}, nil
+	}
+	tierIndex := (ordinal - 1) / divisionsPerTier
+	if ordinal < 0 || tierIndex >= len(rankTiers) || (rankTiers[tierIndex] == championTier && ordinal != tierIndex*divisionsPerTier+1) {
+		return Rank{}, fmt.Errorf("invalid rank ordinal %d", ordinal)
+	}
+	tier := rankTiers[tierIndex]
+	if tier == championTier {
+		return Rank{Tier: tier}, nil
+	}
+	return Rank{Tier: tier, Division: divisionsPerTier - (ordinal-1)%divisionsPerTier}, nil
+}
+
+// RankSlug is a parsed tabstats rank slug like "31-gold-2".
+type RankSlug struct {
+	// SeasonID is 0 if the slug does not contain a season.
+	SeasonID int
+	Rank
+}
+
+// ParseRankSlug parses tabstats rank slugs in the formats "<season>-<tier>-<division>", "<season>-champion",
+// "<season>-unranked" and "<season>". Empty slugs are treated as unranked.
+func ParseRankSlug(slug string) (RankSlug, error) {
+	result := RankSlug{Rank: Rank{Tier: RankUnranked}}
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(slug)), "-")
+	if len(parts) == 1 && parts[0] == "" {
+		return result, nil
+	}
+
+	if seasonID, err := strconv.Atoi(parts[0]); err == nil {
+		result.SeasonID = seasonID
+		parts = parts[1:]
+	}
+	if len(parts) == 0 || parts[0] == RankUnranked || parts[0] == "placement" || parts[0] == "" {
+		return result, nil
+	}
+
+	tier := parts[0]
+	validTier := false
+	for _, t := range rankTiers {
+		if t == tier {
+			validTier = true
+			break
+		}
+	}
+	if !validTier {
+		return result, fmt.Errorf("unknown rank tier '%s' in rank slug '%s'", tier, slug)
+	}
+	result.Tier = tier
+
+	if tier == championTier {
+		return result, nil
+	}
+	if len(parts) != 2 {
+		return result, fmt.Errorf("missing division in rank slug '%s'", slug)
+	}
+	division, ok := romanDivisions[parts[1]]
+	if !ok {
+		var err error
+		if division, err = strconv.Atoi(parts[1]); err != nil || division < 1 || division > divisionsPerTier {
+			return result, fmt.Errorf("invalid division '%s' in rank slug '%s'", parts[1], slug)
+		}
+	}
+	result.Division = division
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import "testing"
+
+func TestRankOrdinal(t *testing.T) {
+	tests := []struct {
+		rank Rank
+		want int
+	}{
+		{Rank{Tier: RankUnranked}, 0},
+		{Rank{}, 0},
+		{Rank{Tier: "copper", Division: 5}, 1},
+		{Rank{Tier: "copper", Division: 1}, 5},
+		{Rank{Tier: "bronze", Division: 5}, 6},
+		{Rank{Tier: "gold", Division: 2}, 19},
+		{Rank{Tier: "diamond", Division: 1}, 35},
+		{Rank{Tier: "champion"}, 36},
+	}
+	for _, tt := range tests {
+		if got := tt.rank.Ordinal(); got != tt.want {
+			t.Errorf("%+v.Ordinal() = %d, want %d", tt.rank, got, tt.want)
+		}
+	}
+}
+
+func TestRankFromOrdinal(t *testing.T) {
+	for ordinal := 0; ordinal <= 36; ordinal++ {
+		rank, err := RankFromOrdinal(ordinal)
+		if err != nil {
+			t.Errorf("RankFromOrdinal(%d) returned error: %v", ordinal, err)
+			continue
+		}
+		if got := rank.Ordinal(); got != ordinal {
+			t.Errorf("RankFromOrdinal(%d) = %+v with ordinal %d", ordinal, rank, got)
+		}
+	}
+	for _, ordinal := range []int{-1, 37, 40} {
+		if rank, err := RankFromOrdinal(ordinal); err == nil {
+			t.Errorf("RankFromOrdinal(%d) = %+v, want error", ordinal, rank)
+		}
+	}
+}
+
+func TestRankString(t *testing.T) {
+	tests := []struct {
+		rank Rank
+		want string
+	}{
+		{Rank{}, ""},
+		{Rank{Tier: RankUnranked}, "Unranked"},
+		{Rank{Tier: "gold", Division: 2}, "Gold II"},
+		{Rank{Tier: "copper", Division: 5}, "Copper V"},
+		{Rank{Tier: "champion"}, "Champion"},
+	}
+	for _, tt := range tests {
+		if got := tt.rank.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.rank, got, tt.want)
+		}
+	}
+}
+
+func TestParseRankSlug(t *testing.T) {
+	tests := []struct {
+		slug    string
+		want    RankSlug
+		wantErr bool
+	}{
+		{"", RankSlug{Rank: Rank{Tier: RankUnranked}}, false},
+		{"31", RankSlug{SeasonID: 31, Rank: Rank{Tier: RankUnranked}}, false},
+		{"31-unranked", RankSlug{SeasonID: 31, Rank: Rank{Tier: RankUnranked}}, false},
+		{"31-placement", RankSlug{SeasonID: 31, Rank: Rank{Tier: RankUnranked}}, false},
+		{"31-gold-2", RankSlug{SeasonID: 31, Rank: Rank{Tier: "gold", Division: 2}}, false},
+		{"31-Gold-II", RankSlug{SeasonID: 31, Rank: Rank{Tier: "gold", Division: 2}}, false},
+		{" 29-copper-v ", RankSlug{SeasonID: 29, Rank: Rank{Tier: "copper", Division: 5}}, false},
+		{"platinum-1", RankSlug{Rank: Rank{Tier: "platinum", Division: 1}}, false},
+		{"31-champion", RankSlug{SeasonID: 31, Rank: Rank{Tier: "champion"}}, false},
+		{"31-gold", RankSlug{}, true},
+		{"31-gold-6", RankSlug{}, true},
+		{"31-gold-x", RankSlug{}, true},
+		{"31-wood-1", RankSlug{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRankSlug(tt.slug)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRankSlug(%q) = %+v, want error", tt.slug, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRankSlug(%q) returned error: %v", tt.slug, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRankSlug(%q) = %+v, want %+v", tt.slug, got, tt.want)
+		}
+	}
+}
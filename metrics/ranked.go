@@ -2,15 +2,88 @@ package metrics
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/stnokott/r6api"
 	"github.com/stnokott/r6api/types/metadata"
+	"github.com/stnokott/r6api/types/ranked"
 )
 
-func SendRankedStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, t time.Time, chData chan<- StatResponse) {
-	seasons, err := api.GetRankedHistory(profile, 1)
+// DefaultRankedHistoryDepth only requests the most recent ranked season.
+const DefaultRankedHistoryDepth = 1
+
+// RankedCollector sends the ranked history of a profile, one point per season.
+// Past seasons do not change anymore, so they are only written again if their values differ from the last written ones.
+// The last written values can be persisted across restarts using Fingerprints and RestoreFingerprints.
+//
+// The ranked history is requested once per profile and run, it is shared with the Ubisoft ranked provider.
+type RankedCollector struct {
+	depth int
+
+	mu      sync.Mutex
+	written map[string]string
+
+	historyMu sync.Mutex
+	history   map[string]*rankedHistory
+}
+
+// rankedHistory is the ranked history response of a single profile and depth.
+type rankedHistory struct {
+	once    sync.Once
+	seasons []ranked.SeasonStats
+	err     error
+}
+
+// NewRankedCollector creates a collector requesting depth seasons of ranked history.
+func NewRankedCollector(depth int) *RankedCollector {
+	if depth < 1 {
+		depth = DefaultRankedHistoryDepth
+	}
+	return &RankedCollector{
+		depth:   depth,
+		written: map[string]string{},
+		history: map[string]*rankedHistory{},
+	}
+}
+
+// getRankedHistory requests the ranked history of a profile, concurrent and later calls within a run share the response.
+func (c *RankedCollector) getRankedHistory(api *r6api.R6API, profile *r6api.Profile, depth int) ([]ranked.SeasonStats, error) {
+	key := fmt.Sprintf("%s/%d", profile.ProfileID, depth)
+	c.historyMu.Lock()
+	h, ok := c.history[key]
+	if !ok {
+		h = &rankedHistory{}
+		c.history[key] = h
+	}
+	c.historyMu.Unlock()
+
+	h.once.Do(func() {
+		h.seasons, h.err = api.GetRankedHistory(profile, depth)
+	})
+	return h.seasons, h.err
+}
+
+// ResetCache discards the ranked histories of the previous run.
+func (c *RankedCollector) ResetCache() {
+	c.historyMu.Lock()
+	defer c.historyMu.Unlock()
+	c.history = map[string]*rankedHistory{}
+}
+
+// SendRankedStats is a StatSenderFunc sending the ranked history.
+// The current season is always written, past seasons only if they changed. When taking a final
+// snapshot of a season, only that season is written.
+func (c *RankedCollector) SendRankedStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, season Season, t time.Time, chData chan<- StatResponse) {
+	depth := c.depth
+	if season.Final && depth < 2 {
+		// previous season is second in history
+		depth = 2
+	}
+	seasons, err := c.getRankedHistory(api, profile, depth)
 	if err != nil {
 		chData <- StatResponse{Err: err}
 		return
@@ -19,24 +92,88 @@ func SendRankedStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Me
 		chData <- StatResponse{Err: fmt.Errorf("got no ranked history for user %s", profile.Name)}
 		return
 	}
-	stats := seasons[0]
-
-	chData <- StatResponse{
-		P: influxdb2.NewPoint(
-			"ranked",
-			map[string]string{
-				"season_slug": meta.SeasonSlugFromID(stats.SeasonID),
-				"season_name": meta.SeasonNameFromID(stats.SeasonID),
-				"username":    profile.Name,
-			},
-			map[string]interface{}{
-				"mmr":         stats.MMR,
-				"rank":        stats.Rank,
-				"skill_mean":  stats.SkillMean,
-				"skill_stdev": stats.SkillStdev,
-			},
-			t,
-		),
+
+	for _, stats := range seasons {
+		seasonSlug := meta.SeasonSlugFromID(stats.SeasonID)
+		if seasonSlug == "" {
+			continue
+		}
+		isRequested := seasonSlug == season.Slug
+		if season.Final && !isRequested {
+			continue
+		}
+
+		fields := structFields(stats)
+		// already present as season tags
+		delete(fields, "season_id")
+
+		if !isRequested && !c.changed(profile.ProfileID, seasonSlug, fields) {
+			continue
+		}
+
+		chData <- StatResponse{
+			P: influxdb2.NewPoint(
+				"ranked",
+				map[string]string{
+					"season_slug": seasonSlug,
+					"season_name": meta.SeasonNameFromID(stats.SeasonID),
+					"username":    profile.Name,
+				},
+				fields,
+				t,
+			),
+		}
 	}
+	chData <- StatResponse{Raw: &RawStats{Collector: "ranked", Measurements: []string{"ranked"}, Data: seasons}}
 	chData <- StatResponse{Done: true}
 }
+
+// changed records the fields of a past season and reports whether they differ from the previously recorded ones.
+func (c *RankedCollector) changed(profileID string, seasonSlug string, fields map[string]interface{}) bool {
+	key := profileID + "/" + seasonSlug
+	fingerprint := fieldsFingerprint(fields)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.written[key] == fingerprint {
+		return false
+	}
+	c.written[key] = fingerprint
+	return true
+}
+
+// Fingerprints returns the recorded fields of past seasons, keyed by profile ID and season slug, for persisting them.
+func (c *RankedCollector) Fingerprints() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fingerprints := make(map[string]string, len(c.written))
+	for k, v := range c.written {
+		fingerprints[k] = v
+	}
+	return fingerprints
+}
+
+// RestoreFingerprints restores fingerprints previously returned by Fingerprints,
+// so unchanged past seasons are not written again after a restart.
+func (c *RankedCollector) RestoreFingerprints(fingerprints map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range fingerprints {
+		c.written[k] = v
+	}
+}
+
+// fieldsFingerprint returns a stable string representation of fields.
+func fieldsFingerprint(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%v;", k, fields[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/stnokott/r6api"
+	"github.com/stnokott/r6api/types/metadata"
+)
+
+// Ranked provider names
+const (
+	RankedSourceUbisoft  = "ubisoft"
+	RankedSourceTabStats = "tabstats"
+)
+
+// DefaultRankedProviders is the default provider order, Ubisoft first with tabstats as fallback.
+var DefaultRankedProviders = []string{RankedSourceUbisoft, RankedSourceTabStats}
+
+// DefaultRankedDiscrepancyThreshold is the MMR difference above which sources are considered to disagree.
+const DefaultRankedDiscrepancyThreshold = 50
+
+// ErrNoRankedStats is returned by providers if the profile has no ranked stats for the requested season.
+var ErrNoRankedStats = errors.New("no ranked stats for season")
+
+// RankedSnapshot is the current ranked state of a profile as reported by a single provider.
+type RankedSnapshot struct {
+	MMR int
+	// Rank is the rank ordinal as returned by Rank.Ordinal.
+	Rank int
+}
+
+// RankedProvider is a source for the current ranked state of a profile.
+type RankedProvider interface {
+	Name() string
+	CurrentRanked(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, season Season) (*RankedSnapshot, error)
+}
+
+// ubisoftRankedProvider takes the current season from the ranked history requested by the RankedCollector.
+type ubisoftRankedProvider struct {
+	history *RankedCollector
+}
+
+func (ubisoftRankedProvider) Name() string {
+	return RankedSourceUbisoft
+}
+
+func (p ubisoftRankedProvider) CurrentRanked(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, season Season) (*RankedSnapshot, error) {
+	seasons, err := p.history.getRankedHistory(api, profile, p.history.depth)
+	if err != nil {
+		return nil, err
+	}
+	if len(seasons) == 0 || meta.SeasonSlugFromID(seasons[0].SeasonID) != season.Slug {
+		return nil, ErrNoRankedStats
+	}
+	return &RankedSnapshot{
+		MMR:  seasons[0].MMR,
+		Rank: seasons[0].Rank,
+	}, nil
+}
+
+// Name implements RankedProvider.
+func (c *TabStatsClient) Name() string {
+	return RankedSourceTabStats
+}
+
+// CurrentRanked implements RankedProvider.
+func (c *TabStatsClient) CurrentRanked(_ *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, season Season) (*RankedSnapshot, error) {
+	tabStats, err := c.getRankedTabStats(profile)
+	if err != nil {
+		return nil, err
+	}
+	rankSlug, err := ParseRankSlug(tabStats.CurrentSeason.Ranked.RankSlug)
+	if err != nil {
+		return nil, err
+	}
+	if !rankSlug.inSeason(meta, season) {
+		return nil, ErrNoRankedStats
+	}
+	return &RankedSnapshot{
+		MMR:  tabStats.CurrentSeason.Ranked.MMR,
+		Rank: rankSlug.Ordinal(),
+	}, nil
+}
+
+// NewRankedProviders returns the providers with the given names in order.
+// The Ubisoft provider shares the ranked history requested by history.
+func NewRankedProviders(names []string, tabStats *TabStatsClient, history *RankedCollector) ([]RankedProvider, error) {
+	providers := make([]RankedProvider, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case RankedSourceUbisoft:
+			providers = append(providers, ubisoftRankedProvider{history: history})
+		case RankedSourceTabStats:
+			providers = append(providers, tabStats)
+		default:
+			return nil, fmt.Errorf("unknown ranked provider '%s'", name)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("at least one ranked provider is required")
+	}
+	return providers, nil
+}
+
+// RankedReconciler combines the current ranked state of multiple providers.
+// The first provider which succeeds determines the written values, all other providers are
+// queried to detect disagreements between the sources.
+type RankedReconciler struct {
+	providers []RankedProvider
+	threshold int
+}
+
+func NewRankedReconciler(providers []RankedProvider, discrepancyThreshold int) *RankedReconciler {
+	return &RankedReconciler{
+		providers: providers,
+		threshold: discrepancyThreshold,
+	}
+}
+
+// SendRankedStats is a StatSenderFunc writing the ranked_combined measurement and ranked_discrepancy events.
+func (r *RankedReconciler) SendRankedStats(api *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, season Season, t time.Time, chData chan<- StatResponse) {
+	if season.Final {
+		// not all providers can provide past seasons
+		chData <- StatResponse{Done: true}
+		return
+	}
+
+	type result struct {
+		source   string
+		snapshot *RankedSnapshot
+	}
+	var results []result
+	var errs []error
+	for _, p := range r.providers {
+		snapshot, err := p.CurrentRanked(api, profile, meta, season)
+		if err != nil {
+			if !errors.Is(err, ErrNoRankedStats) {
+				errs = append(errs, fmt.Errorf("ranked provider %s: %w", p.Name(), err))
+			}
+			continue
+		}
+		results = append(results, result{p.Name(), snapshot})
+	}
+	if len(results) == 0 {
+		if len(errs) > 0 {
+			chData <- StatResponse{Err: errors.Join(errs...)}
+		} else {
+			chData <- StatResponse{Done: true}
+		}
+		return
+	}
+	// the result of a fallback is written, but the failed providers are still reported
+	for _, err := range errs {
+		chData <- StatResponse{Warning: err}
+	}
+
+	primary := results[0]
+	tags := map[string]string{
+		"season_slug": season.Slug,
+		"season_name": season.Name,
+		"username":    profile.Name,
+	}
+	fields := map[string]interface{}{
+		"mmr":       primary.snapshot.MMR,
+		"rank":      primary.snapshot.Rank,
+		"fallback":  primary.source != r.providers[0].Name(),
+		"providers": len(results),
+	}
+
+	maxDiff := 0
+	for _, other := range results[1:] {
+		diff := primary.snapshot.MMR - other.snapshot.MMR
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > maxDiff {
+			maxDiff = diff
+		}
+		if diff > r.threshold {
+			chData <- StatResponse{
+				P: influxdb2.NewPoint(
+					"ranked_discrepancy",
+					map[string]string{
+						"season_slug":      season.Slug,
+						"season_name":      season.Name,
+						"username":         profile.Name,
+						"source":           primary.source,
+						"secondary_source": other.source,
+					},
+					map[string]interface{}{
+						"mmr":            primary.snapshot.MMR,
+						"secondary_mmr":  other.snapshot.MMR,
+						"mmr_difference": diff,
+						"rank":           primary.snapshot.Rank,
+						"secondary_rank": other.snapshot.Rank,
+					},
+					t,
+				),
+			}
+		}
+	}
+	if len(results) > 1 {
+		fields["mmr_discrepancy"] = maxDiff
+	}
+
+	tags["source"] = primary.source
+	chData <- StatResponse{
+		P: influxdb2.NewPoint("ranked_combined", tags, fields, t),
+	}
+	chData <- StatResponse{Done: true}
+}
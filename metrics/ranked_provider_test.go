@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stnokott/r6api"
+	"github.com/stnokott/r6api/types/metadata"
+)
+
+// sendResponses runs a StatSenderFunc for a single profile and returns all responses until it finished.
+func sendResponses(send StatSenderFunc, season Season) []StatResponse {
+	chData := make(chan StatResponse, 10)
+	send(nil, &r6api.Profile{Name: "alice", ProfileID: "profile"}, &metadata.Metadata{}, season, time.Unix(1700000000, 0), chData)
+	var responses []StatResponse
+	for {
+		resp := <-chData
+		responses = append(responses, resp)
+		if resp.Done || resp.Err != nil {
+			return responses
+		}
+	}
+}
+
+func TestRankedReconciler(t *testing.T) {
+	errDown := errors.New("provider down")
+	tests := []struct {
+		name         string
+		providers    []RankedProvider
+		final        bool
+		wantErr      bool
+		wantWarnings int
+		// wantCombined are the fields of the ranked_combined point, nil if none is written
+		wantCombined map[string]interface{}
+		wantSource   string
+		wantDiff     int64
+	}{
+		{
+			name:         "agreeing providers",
+			providers:    []RankedProvider{testRankedProvider{name: "a", mmr: 3000}, testRankedProvider{name: "b", mmr: 3020}},
+			wantCombined: map[string]interface{}{"mmr": int64(3000), "rank": int64(20), "fallback": false, "providers": int64(2), "mmr_discrepancy": int64(20)},
+			wantSource:   "a",
+		},
+		{
+			name:         "fallback reports failed provider",
+			providers:    []RankedProvider{testRankedProvider{name: "a", err: errDown}, testRankedProvider{name: "b", mmr: 3020}},
+			wantWarnings: 1,
+			wantCombined: map[string]interface{}{"mmr": int64(3020), "rank": int64(20), "fallback": true, "providers": int64(1)},
+			wantSource:   "b",
+		},
+		{
+			name:         "missing stats are no error",
+			providers:    []RankedProvider{testRankedProvider{name: "a", err: ErrNoRankedStats}, testRankedProvider{name: "b", mmr: 3020}},
+			wantCombined: map[string]interface{}{"mmr": int64(3020), "rank": int64(20), "fallback": true, "providers": int64(1)},
+			wantSource:   "b",
+		},
+		{
+			name:         "discrepancy",
+			providers:    []RankedProvider{testRankedProvider{name: "a", mmr: 3000}, testRankedProvider{name: "b", mmr: 2900}},
+			wantCombined: map[string]interface{}{"mmr": int64(3000), "rank": int64(20), "fallback": false, "providers": int64(2), "mmr_discrepancy": int64(100)},
+			wantSource:   "a",
+			wantDiff:     100,
+		},
+		{
+			name:      "all failed",
+			providers: []RankedProvider{testRankedProvider{name: "a", err: errDown}, testRankedProvider{name: "b", err: errDown}},
+			wantErr:   true,
+		},
+		{
+			name:      "no stats",
+			providers: []RankedProvider{testRankedProvider{name: "a", err: ErrNoRankedStats}},
+		},
+		{
+			name:      "final season",
+			providers: []RankedProvider{testRankedProvider{name: "a", mmr: 3000}},
+			final:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRankedReconciler(tt.providers, DefaultRankedDiscrepancyThreshold)
+			responses := sendResponses(r.SendRankedStats, Season{Slug: "Y8S4", Final: tt.final})
+
+			warnings := 0
+			var combined map[string]interface{}
+			var source string
+			var diff int64
+			for _, resp := range responses {
+				switch {
+				case resp.Warning != nil:
+					warnings++
+				case resp.P != nil && resp.P.Name() == "ranked_combined":
+					combined, source = PointFields(resp.P), PointTags(resp.P)["source"]
+				case resp.P != nil && resp.P.Name() == "ranked_discrepancy":
+					diff = PointFields(resp.P)["mmr_difference"].(int64)
+				}
+			}
+			if last := responses[len(responses)-1]; (last.Err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %t", last.Err, tt.wantErr)
+			}
+			if warnings != tt.wantWarnings {
+				t.Errorf("got %d warnings, want %d", warnings, tt.wantWarnings)
+			}
+			if !fieldsEqual(combined, tt.wantCombined) || source != tt.wantSource {
+				t.Errorf("ranked_combined = %v from %q, want %v from %q", combined, source, tt.wantCombined, tt.wantSource)
+			}
+			if diff != tt.wantDiff {
+				t.Errorf("discrepancy = %d, want %d", diff, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func fieldsEqual(a map[string]interface{}, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTabStatsCurrentRankedSeason(t *testing.T) {
+	tests := []struct {
+		slug    string
+		wantErr error
+	}{
+		{"gold-2", nil},
+		// a season ID unknown to the metadata is not the current season, e.g. the previous one after a rollover
+		{"9999-gold-2", ErrNoRankedStats},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"current_season_records": {"ranked": {"mmr": 3000, "rank_slug": "` + tt.slug + `"}}}`))
+		}))
+		c := NewTabStatsClient(server.URL, time.Second)
+		snapshot, err := c.CurrentRanked(nil, &r6api.Profile{ProfileID: "profile"}, &metadata.Metadata{}, Season{Slug: "Y8S4"})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.slug, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && (snapshot == nil || snapshot.MMR != 3000) {
+			t.Errorf("%s: snapshot = %+v, want MMR 3000", tt.slug, snapshot)
+		}
+
+		points := 0
+		for _, resp := range sendResponses(c.SendRankedStats, Season{Slug: "Y8S4"}) {
+			if resp.P != nil {
+				points++
+			}
+		}
+		if want := map[bool]int{true: 1, false: 0}[tt.wantErr == nil]; points != want {
+			t.Errorf("%s: ranked_tabstats wrote %d points, want %d", tt.slug, points, want)
+		}
+		server.Close()
+	}
+}
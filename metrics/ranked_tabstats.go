@@ -1,15 +1,12 @@
 package metrics
 
 import (
-	"encoding/json"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/stnokott/r6api"
-	"github.com/stnokott/r6api/constants"
 	"github.com/stnokott/r6api/types/metadata"
 )
 
@@ -23,62 +20,62 @@ type rankedTabStats struct {
 	} `json:"current_season_records"`
 }
 
-const tabStatsBaseURL = "https://r6.apitab.net/website/profiles/"
-
-func getRankedTabStats(profile *r6api.Profile) (result *rankedTabStats, err error) {
-	requestURL := tabStatsBaseURL + profile.ProfileID + "?update=true"
-	req, _ := http.NewRequest("GET", requestURL, nil)
-	req.Header.Add("User-Agent", constants.USER_AGENT)
-	req.Header.Add("Accept", "application/json")
-	var resp *http.Response
-	resp, err = http.DefaultClient.Do(req)
-	if err != nil {
+// SendRankedStats is a StatSenderFunc sending the current ranked stats from tabstats.
+func (c *TabStatsClient) SendRankedStats(_ *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, season Season, t time.Time, chData chan<- StatResponse) {
+	if season.Final {
+		// tabstats only provides the current season
+		chData <- StatResponse{Done: true}
 		return
 	}
-
-	defer func() {
-		innerErr := resp.Body.Close()
-		if err == nil {
-			err = innerErr
-		}
-	}()
-
-	result = new(rankedTabStats)
-	err = json.NewDecoder(resp.Body).Decode(result)
-	return
-}
-
-func SendRankedTabStatsStats(_ *r6api.R6API, profile *r6api.Profile, meta *metadata.Metadata, t time.Time, chData chan<- StatResponse) {
-	tabStats, err := getRankedTabStats(profile)
+	tabStats, err := c.getRankedTabStats(profile)
 	if err != nil {
 		chData <- StatResponse{Err: err}
 		return
 	}
 
-	currentSeason := meta.Seasons[len(meta.Seasons)-1]
-	rankSlugSplit := strings.SplitN(tabStats.CurrentSeason.Ranked.RankSlug, "-", 2)
-	seasonID, err := strconv.Atoi(rankSlugSplit[0])
+	rawSlug := tabStats.CurrentSeason.Ranked.RankSlug
+	rankSlug, err := ParseRankSlug(rawSlug)
 	if err != nil {
 		chData <- StatResponse{Err: err}
 		return
 	}
+	if !rankSlug.inSeason(meta, season) {
+		// tabstats still reports the previous season shortly after a rollover
+		chData <- StatResponse{Done: true}
+		return
+	}
+
+	tags := map[string]string{
+		"season_slug": season.Slug,
+		"season_name": season.Name,
+		"username":    profile.Name,
+	}
+	if rankSlug.SeasonID != 0 {
+		tags["season_id"] = strconv.Itoa(rankSlug.SeasonID)
+		rawSlug = strings.TrimPrefix(rawSlug, strconv.Itoa(rankSlug.SeasonID)+"-")
+	}
 
 	chData <- StatResponse{
 		P: influxdb2.NewPoint(
 			"ranked_tabstats",
-			map[string]string{
-				"season_slug": currentSeason.Slug,
-				"season_name": currentSeason.Name,
-				"season_id":   strconv.Itoa(seasonID),
-				"username":    profile.Name,
-			},
+			tags,
 			map[string]interface{}{
-				"mmr":       tabStats.CurrentSeason.Ranked.MMR,
-				"real_mmr":  tabStats.CurrentSeason.Ranked.RealMMR,
-				"rank_slug": rankSlugSplit[1],
+				"mmr":           tabStats.CurrentSeason.Ranked.MMR,
+				"real_mmr":      tabStats.CurrentSeason.Ranked.RealMMR,
+				"rank_slug":     rawSlug,
+				"rank":          rankSlug.Ordinal(),
+				"rank_tier":     rankSlug.Tier,
+				"rank_division": rankSlug.Division,
+				"rank_name":     rankSlug.String(),
 			},
 			t,
 		),
 	}
+	chData <- StatResponse{Raw: &RawStats{Collector: "ranked_tabstats", Measurements: []string{"ranked_tabstats"}, Data: tabStats}}
 	chData <- StatResponse{Done: true}
 }
+
+// inSeason reports whether the rank belongs to season. Slugs without season are assumed to be of the current season.
+func (s RankSlug) inSeason(meta *metadata.Metadata, season Season) bool {
+	return s.SeasonID == 0 || meta.SeasonSlugFromID(s.SeasonID) == season.Slug
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"sort"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// Rewriter changes measurement names and tags of written points, e.g. to share a bucket with other exporters.
+// It is applied when writing, all internal processing uses the original names.
+type Rewriter struct {
+	prefix       string
+	measurements map[string]string
+	tagKeys      map[string]string
+	staticTags   map[string]string
+}
+
+// NewRewriter creates a rewriter. Measurements are renamed first, then prefixed.
+// Static tags are added to every point unless it already has a tag with the same key.
+func NewRewriter(prefix string, measurements map[string]string, tagKeys map[string]string, staticTags map[string]string) *Rewriter {
+	return &Rewriter{
+		prefix:       prefix,
+		measurements: measurements,
+		tagKeys:      tagKeys,
+		staticTags:   staticTags,
+	}
+}
+
+func (r *Rewriter) isNoop() bool {
+	return r == nil || (r.prefix == "" && len(r.measurements) == 0 && len(r.tagKeys) == 0 && len(r.staticTags) == 0)
+}
+
+// Measurement returns the written name of a measurement.
+func (r *Rewriter) Measurement(name string) string {
+	if r == nil {
+		return name
+	}
+	if renamed, ok := r.measurements[name]; ok {
+		name = renamed
+	}
+	return r.prefix + name
+}
+
+// TagKey returns the written key of a tag.
+func (r *Rewriter) TagKey(key string) string {
+	if r == nil {
+		return key
+	}
+	if renamed, ok := r.tagKeys[key]; ok {
+		return renamed
+	}
+	return key
+}
+
+// Apply returns a copy of p with renamed measurement and tags and the static tags added.
+// If the rewriter does nothing, p is returned unchanged.
+func (r *Rewriter) Apply(p *write.Point) *write.Point {
+	if r.isNoop() {
+		return p
+	}
+	tags := make(map[string]string, len(p.TagList())+len(r.staticTags))
+	for _, tag := range p.TagList() {
+		tags[r.TagKey(tag.Key)] = tag.Value
+	}
+	for k, v := range r.staticTags {
+		if _, exists := tags[k]; !exists {
+			tags[k] = v
+		}
+	}
+	return influxdb2.NewPoint(r.Measurement(p.Name()), tags, PointFields(p), p.Time())
+}
+
+// ApplySchema returns the schema with the names as written.
+func (r *Rewriter) ApplySchema(s Schema) Schema {
+	if r.isNoop() {
+		return s
+	}
+	result := Schema{Version: s.Version, Measurements: make([]MeasurementSchema, len(s.Measurements))}
+	staticKeys := make([]string, 0, len(r.staticTags))
+	for k := range r.staticTags {
+		staticKeys = append(staticKeys, k)
+	}
+	sort.Strings(staticKeys)
+
+	for i, m := range s.Measurements {
+		tags := make([]string, 0, len(m.Tags)+len(staticKeys))
+		known := map[string]bool{}
+		for _, tag := range m.Tags {
+			tag = r.TagKey(tag)
+			tags = append(tags, tag)
+			known[tag] = true
+		}
+		for _, tag := range staticKeys {
+			if !known[tag] {
+				tags = append(tags, tag)
+			}
+		}
+		result.Measurements[i] = MeasurementSchema{
+			Name:        r.Measurement(m.Name),
+			Description: m.Description,
+			Tags:        tags,
+			Fields:      m.Fields,
+		}
+	}
+	return result
+}
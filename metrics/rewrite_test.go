@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+func TestRewriterNames(t *testing.T) {
+	r := NewRewriter("r6_", map[string]string{"actions": "operators"}, map[string]string{"username": "player"}, nil)
+	tests := []struct {
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{r.Measurement, "maps", "r6_maps"},
+		{r.Measurement, "actions", "r6_operators"},
+		{r.TagKey, "username", "player"},
+		{r.TagKey, "map", "map"},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); got != tt.want {
+			t.Errorf("rewrite(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	var nilRewriter *Rewriter
+	if got := nilRewriter.Measurement("maps"); got != "maps" {
+		t.Errorf("nil rewriter Measurement() = %q", got)
+	}
+	if got := nilRewriter.TagKey("username"); got != "username" {
+		t.Errorf("nil rewriter TagKey() = %q", got)
+	}
+}
+
+func TestRewriterApply(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	p := influxdb2.NewPoint("actions", map[string]string{"username": "a", "env": "point"}, map[string]interface{}{"kills": 1}, ts)
+
+	tests := []struct {
+		name            string
+		rewriter        *Rewriter
+		wantMeasurement string
+		wantTags        map[string]string
+	}{
+		{"nil", nil, "actions", map[string]string{"username": "a", "env": "point"}},
+		{"noop", NewRewriter("", nil, nil, nil), "actions", map[string]string{"username": "a", "env": "point"}},
+		{"prefix", NewRewriter("r6_", nil, nil, nil), "r6_actions", map[string]string{"username": "a", "env": "point"}},
+		{"rename then prefix", NewRewriter("r6_", map[string]string{"actions": "operators"}, nil, nil), "r6_operators", map[string]string{"username": "a", "env": "point"}},
+		{"tag rename", NewRewriter("", nil, map[string]string{"username": "player"}, nil), "actions", map[string]string{"player": "a", "env": "point"}},
+		{
+			"static tags do not override point tags",
+			NewRewriter("", nil, nil, map[string]string{"env": "static", "host": "h"}),
+			"actions",
+			map[string]string{"username": "a", "env": "point", "host": "h"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rewriter.Apply(p)
+			if got.Name() != tt.wantMeasurement {
+				t.Errorf("measurement = %q, want %q", got.Name(), tt.wantMeasurement)
+			}
+			if !reflect.DeepEqual(PointTags(got), tt.wantTags) {
+				t.Errorf("tags = %v, want %v", PointTags(got), tt.wantTags)
+			}
+			if !reflect.DeepEqual(PointFields(got), PointFields(p)) || !got.Time().Equal(ts) {
+				t.Errorf("rewritten point changed fields or time: %v", got)
+			}
+		})
+	}
+	if got := NewRewriter("", nil, nil, nil).Apply(p); got != p {
+		t.Error("noop rewriter should return the point unchanged")
+	}
+}
+
+func TestRewriterApplySchema(t *testing.T) {
+	s := Schema{Version: 2, Measurements: []MeasurementSchema{{
+		Name:   "actions",
+		Tags:   []string{"username", "env"},
+		Fields: []FieldSchema{{"kills", FieldTypeInteger}},
+	}}}
+	r := NewRewriter("r6_", map[string]string{"actions": "operators"}, map[string]string{"username": "player"}, map[string]string{"env": "x", "host": "h"})
+
+	got := r.ApplySchema(s)
+	want := Schema{Version: 2, Measurements: []MeasurementSchema{{
+		Name:   "r6_operators",
+		Tags:   []string{"player", "env", "host"},
+		Fields: []FieldSchema{{"kills", FieldTypeInteger}},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplySchema() = %+v, want %+v", got, want)
+	}
+	if got := (*Rewriter)(nil).ApplySchema(s); !reflect.DeepEqual(got, s) {
+		t.Errorf("nil rewriter changed schema: %+v", got)
+	}
+}
@@ -0,0 +1,312 @@
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6api/types/ranked"
+	"github.com/stnokott/r6api/types/stats"
+)
+
+// SchemaVersion is the version of the measurement, tag and field names written by r6prom.
+// It needs to be increased together with a migration whenever existing names change.
+//
+//	1: initial schema, points carry no version
+//	2: points carry the schema_version field
+const SchemaVersion = 2
+
+// SchemaVersionField is the field holding the schema version on every written point, unless it is denied by the field filter.
+const SchemaVersionField = "schema_version"
+
+// Field types
+const (
+	FieldTypeInteger = "integer"
+	FieldTypeFloat   = "float"
+	FieldTypeBoolean = "boolean"
+	FieldTypeString  = "string"
+)
+
+// Schema describes all measurements written by r6prom.
+type Schema struct {
+	Version      int                 `json:"version"`
+	Measurements []MeasurementSchema `json:"measurements"`
+}
+
+// MeasurementSchema describes a single measurement. Not every point carries all tags and fields.
+type MeasurementSchema struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Tags        []string      `json:"tags"`
+	Fields      []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a single field.
+type FieldSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Measurement returns the schema of a measurement, ok is false if it does not exist.
+func (s Schema) Measurement(name string) (m MeasurementSchema, ok bool) {
+	for _, m := range s.Measurements {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return MeasurementSchema{}, false
+}
+
+// Check returns an error if the measurement, a tag or a field of p is not described by the schema,
+// or if a field has a different type.
+func (s Schema) Check(p *write.Point) error {
+	m, ok := s.Measurement(p.Name())
+	if !ok {
+		return fmt.Errorf("unknown measurement %s", p.Name())
+	}
+	tags := make(map[string]bool, len(m.Tags))
+	for _, tag := range m.Tags {
+		tags[tag] = true
+	}
+	for _, tag := range p.TagList() {
+		if !tags[tag.Key] {
+			return fmt.Errorf("measurement %s has unknown tag %s", p.Name(), tag.Key)
+		}
+	}
+	types := make(map[string]string, len(m.Fields))
+	for _, f := range m.Fields {
+		types[f.Name] = f.Type
+	}
+	for _, f := range p.FieldList() {
+		want, ok := types[f.Key]
+		if !ok {
+			return fmt.Errorf("measurement %s has unknown field %s", p.Name(), f.Key)
+		}
+		if got := fieldType(f.Value); got != want {
+			return fmt.Errorf("field %s.%s has type %s, want %s", p.Name(), f.Key, got, want)
+		}
+	}
+	return nil
+}
+
+var (
+	seasonTags    = []string{"season_slug", "season_name", "username"}
+	aggregateTags = []string{"group"}
+	// enrichment tags and fields are only present if enrichment is enabled
+	operatorEnrichmentTags = []string{"side", "operator_role"}
+	mapEnrichmentFields    = []FieldSchema{{"ranked_pool", FieldTypeBoolean}}
+)
+
+// CurrentSchema returns the schema of the current SchemaVersion.
+func CurrentSchema() Schema {
+	mapFields := statSchemaFields(stats.NamedMapStatDetails{})
+	detailedFields := statSchemaFields(stats.DetailedStats{})
+	aggregateFields := []FieldSchema{{"members", FieldTypeInteger}}
+
+	rankedFields := schemaFields(structFields(ranked.SeasonStats{}))
+	rankedFields = removeField(rankedFields, "season_id")
+	rankedFields = append(rankedFields, rankNameFields("rank")...)
+	rankedFields = append(rankedFields, rankNameFields("max_rank")...)
+
+	measurements := []MeasurementSchema{
+		{
+			Name:        "maps",
+			Description: "stats per map and game mode",
+			Tags:        concat(seasonTags, []string{"gamemode", "map"}, aggregateTags),
+			Fields:      concat(mapFields, mapEnrichmentFields, aggregateFields),
+		},
+		{
+			Name:        "bombsites",
+			Description: "stats per bombsite, map, game mode and team role",
+			Tags:        concat(seasonTags, []string{"gamemode", "map", "role", "bombsite"}, aggregateTags),
+			Fields:      concat(detailedFields, mapEnrichmentFields, aggregateFields),
+		},
+		{
+			Name:        "actions",
+			Description: "stats per operator, game mode and team role",
+			Tags:        concat(seasonTags, []string{"gamemode", "role", "operator"}, operatorEnrichmentTags, aggregateTags),
+			Fields:      concat(detailedFields, aggregateFields),
+		},
+		{
+			Name:        "matches",
+			Description: "match counters per game mode",
+			Tags:        concat(seasonTags, []string{"gamemode"}, aggregateTags),
+			Fields:      concat(statSchemaFields(stats.SummarizedGameModeStats{}), aggregateFields),
+		},
+		{
+			Name:        "ranked",
+			Description: "ranked stats per season from Ubisoft",
+			Tags:        seasonTags,
+			Fields:      rankedFields,
+		},
+		{
+			Name:        "ranked_tabstats",
+			Description: "current ranked stats from tabstats",
+			Tags:        concat(seasonTags, []string{"season_id"}),
+			Fields: []FieldSchema{
+				{"mmr", FieldTypeInteger},
+				{"real_mmr", FieldTypeInteger},
+				{"rank_slug", FieldTypeString},
+				{"rank", FieldTypeInteger},
+				{"rank_tier", FieldTypeString},
+				{"rank_division", FieldTypeInteger},
+				{"rank_name", FieldTypeString},
+			},
+		},
+		{
+			Name:        "ranked_combined",
+			Description: "current ranked stats from the preferred available provider",
+			Tags:        concat(seasonTags, []string{"source"}),
+			Fields: concat([]FieldSchema{
+				{"mmr", FieldTypeInteger},
+				{"rank", FieldTypeInteger},
+				{"fallback", FieldTypeBoolean},
+				{"providers", FieldTypeInteger},
+				{"mmr_discrepancy", FieldTypeInteger},
+			}, rankNameFields("rank")),
+		},
+		{
+			Name:        "ranked_discrepancy",
+			Description: "event written when ranked providers disagree",
+			Tags:        concat(seasonTags, []string{"source", "secondary_source"}),
+			Fields: []FieldSchema{
+				{"mmr", FieldTypeInteger},
+				{"secondary_mmr", FieldTypeInteger},
+				{"mmr_difference", FieldTypeInteger},
+				{"rank", FieldTypeInteger},
+				{"secondary_rank", FieldTypeInteger},
+			},
+		},
+		{
+			Name:        "leaderboard",
+			Description: "rankings among observed users",
+			Tags:        []string{"season_slug", "gamemode", "category", "scope", "subject", "username"},
+			Fields: []FieldSchema{
+				{"rank", FieldTypeInteger},
+				{"value", FieldTypeFloat},
+				{"participants", FieldTypeInteger},
+				{"rounds_played", FieldTypeInteger},
+			},
+		},
+		{
+			Name:        "season_change",
+			Description: "event written when a new season starts",
+			Tags:        []string{"season_slug", "season_name", "previous_season_slug", "previous_season_name"},
+			Fields:      []FieldSchema{{"changed", FieldTypeBoolean}},
+		},
+		{
+			Name:        "sessions",
+			Description: "summary of a finished gaming session",
+			Tags:        seasonTags,
+			Fields: []FieldSchema{
+				{"start", FieldTypeInteger},
+				{"duration_seconds", FieldTypeInteger},
+				{"matches", FieldTypeInteger},
+				{"wins", FieldTypeInteger},
+				{"losses", FieldTypeInteger},
+				{"kills", FieldTypeInteger},
+				{"deaths", FieldTypeInteger},
+				{"kd_ratio", FieldTypeFloat},
+				{"mmr_start", FieldTypeInteger},
+				{"mmr_end", FieldTypeInteger},
+				{"mmr_delta", FieldTypeInteger},
+				{"top_operators", FieldTypeString},
+				{"top_maps", FieldTypeString},
+			},
+		},
+		{
+			Name:        "streaks",
+			Description: "win and loss streaks per game mode",
+			Tags:        concat(seasonTags, []string{"gamemode"}),
+			Fields: []FieldSchema{
+				{"current_win_streak", FieldTypeInteger},
+				{"current_loss_streak", FieldTypeInteger},
+				{"longest_win_streak", FieldTypeInteger},
+				{"longest_loss_streak", FieldTypeInteger},
+			},
+		},
+		{
+			Name:        "streak_events",
+			Description: "event written when a streak reaches the configured length",
+			Tags:        concat(seasonTags, []string{"gamemode", "type"}),
+			Fields:      []FieldSchema{{"length", FieldTypeInteger}},
+		},
+	}
+	for i := range measurements {
+		measurements[i].Fields = append(measurements[i].Fields, FieldSchema{SchemaVersionField, FieldTypeInteger})
+	}
+	return Schema{Version: SchemaVersion, Measurements: measurements}
+}
+
+// statSchemaFields returns the fields written by statFields for a stats struct, including all derived fields.
+func statSchemaFields(v interface{}) []FieldSchema {
+	fields := structFields(v)
+	// derived fields are only added for nonzero denominators
+	withValues := make(map[string]interface{}, len(fields))
+	for k := range fields {
+		withValues[k] = 1
+	}
+	AddDerivedFields(withValues)
+	for k, v := range withValues {
+		if _, exists := fields[k]; !exists {
+			fields[k] = v
+		}
+	}
+	return schemaFields(fields)
+}
+
+// schemaFields converts fields to a list sorted by name.
+func schemaFields(fields map[string]interface{}) []FieldSchema {
+	result := make([]FieldSchema, 0, len(fields))
+	for name, v := range fields {
+		result = append(result, FieldSchema{Name: name, Type: fieldType(v)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func fieldType(v interface{}) string {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool:
+		return FieldTypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return FieldTypeInteger
+	case reflect.Float32, reflect.Float64:
+		return FieldTypeFloat
+	default:
+		return FieldTypeString
+	}
+}
+
+// rankNameFields returns the fields added by enrichment for a numeric rank field.
+func rankNameFields(prefix string) []FieldSchema {
+	return []FieldSchema{{prefix + "_name", FieldTypeString}, {prefix + "_tier", FieldTypeString}}
+}
+
+func removeField(fields []FieldSchema, name string) []FieldSchema {
+	result := fields[:0]
+	for _, f := range fields {
+		if f.Name != name {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+func concat[T any](slices ...[]T) []T {
+	var result []T
+	for _, s := range slices {
+		result = append(result, s...)
+	}
+	return result
+}
+
+// StampSchemaVersion returns a copy of p with the schema version field.
+func StampSchemaVersion(p *write.Point) *write.Point {
+	fields := PointFields(p)
+	fields[SchemaVersionField] = SchemaVersion
+	return influxdb2.NewPoint(p.Name(), PointTags(p), fields, p.Time())
+}
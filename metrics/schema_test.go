@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6api"
+	"github.com/stnokott/r6api/types/metadata"
+)
+
+func TestSchemaCheck(t *testing.T) {
+	s := Schema{Measurements: []MeasurementSchema{{
+		Name:   "maps",
+		Tags:   []string{"map"},
+		Fields: []FieldSchema{{"kills", FieldTypeInteger}, {"kd_ratio", FieldTypeFloat}},
+	}}}
+	ts := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		p       *write.Point
+		wantErr bool
+	}{
+		{"valid", influxdb2.NewPoint("maps", map[string]string{"map": "Oregon"}, map[string]interface{}{"kills": 1, "kd_ratio": 1.5}, ts), false},
+		{"subset", influxdb2.NewPoint("maps", nil, map[string]interface{}{"kills": int32(1)}, ts), false},
+		{"unknown measurement", influxdb2.NewPoint("actions", nil, map[string]interface{}{"kills": 1}, ts), true},
+		{"unknown tag", influxdb2.NewPoint("maps", map[string]string{"operator": "Ash"}, map[string]interface{}{"kills": 1}, ts), true},
+		{"unknown field", influxdb2.NewPoint("maps", nil, map[string]interface{}{"deaths": 1}, ts), true},
+		{"wrong type", influxdb2.NewPoint("maps", nil, map[string]interface{}{"kills": 1.5}, ts), true},
+	}
+	for _, tt := range tests {
+		if err := s.Check(tt.p); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Check() error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+type testRankedProvider struct {
+	name string
+	mmr  int
+	err  error
+}
+
+func (p testRankedProvider) Name() string {
+	return p.name
+}
+
+func (p testRankedProvider) CurrentRanked(*r6api.R6API, *r6api.Profile, *metadata.Metadata, Season) (*RankedSnapshot, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &RankedSnapshot{MMR: p.mmr, Rank: 20}, nil
+}
+
+// collect runs a StatSenderFunc for a single profile and returns the sent points.
+func collect(t *testing.T, send StatSenderFunc) []*write.Point {
+	t.Helper()
+	chData := make(chan StatResponse, 10)
+	send(nil, &r6api.Profile{Name: "alice", ProfileID: "profile"}, &metadata.Metadata{}, Season{Slug: "Y8S4", Name: "Deep Freeze"}, time.Unix(1700000000, 0), chData)
+	var points []*write.Point
+	for {
+		resp := <-chData
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		if resp.Done {
+			return points
+		}
+		if resp.P != nil {
+			points = append(points, resp.P)
+		}
+	}
+}
+
+// TestSchemaMatchesCollectors checks the points of the collectors whose schema is not derived from API types.
+func TestSchemaMatchesCollectors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"current_season_records": {"ranked": {"mmr": 3000, "real_mmr": 3010, "rank_slug": "gold-2"}}}`))
+	}))
+	defer server.Close()
+
+	reconciler := NewRankedReconciler([]RankedProvider{
+		testRankedProvider{name: "primary", mmr: 3000},
+		testRankedProvider{name: "secondary", mmr: 2000},
+	}, DefaultRankedDiscrepancyThreshold)
+	leaderboard := &Leaderboard{
+		Time: time.Unix(1700000000, 0),
+		Boards: []*Board{{
+			SeasonSlug: "Y8S4",
+			Gamemode:   "ranked",
+			Category:   "kd_ratio",
+			Scope:      "operator",
+			Subject:    "Ash",
+			Rankings:   []*Ranking{{Rank: 1, Username: "alice", Value: 1.5, RoundsPlayed: 10}},
+		}},
+	}
+
+	points := concat(
+		collect(t, NewTabStatsClient(server.URL, time.Second).SendRankedStats),
+		collect(t, reconciler.SendRankedStats),
+		leaderboard.Points(),
+	)
+	checkSchema(t, points, "ranked_tabstats", "ranked_combined", "ranked_discrepancy", "leaderboard")
+}
+
+// checkSchema checks the stamped points against the current schema and that all given measurements were written.
+func checkSchema(t *testing.T, points []*write.Point, measurements ...string) {
+	t.Helper()
+	schema := CurrentSchema()
+	written := map[string]bool{}
+	for _, p := range points {
+		if err := schema.Check(StampSchemaVersion(p)); err != nil {
+			t.Error(err)
+		}
+		written[p.Name()] = true
+	}
+	for _, m := range measurements {
+		if !written[m] {
+			t.Errorf("no %s point written", m)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/stnokott/r6api/types/metadata"
+)
+
+// Season is the season chosen by the store for a run, shared by all collectors.
+type Season struct {
+	Slug string
+	Name string
+	// Final is set when taking the last snapshot of a season which has just ended.
+	Final bool
+}
+
+// LatestSeason returns the most recent season listed in metadata.
+func LatestSeason(meta *metadata.Metadata) (Season, error) {
+	if len(meta.Seasons) == 0 {
+		return Season{}, errors.New("metadata contains no seasons")
+	}
+	latest := meta.Seasons[len(meta.Seasons)-1]
+	return Season{Slug: latest.Slug, Name: latest.Name}, nil
+}
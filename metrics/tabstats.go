@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stnokott/r6api"
+	"github.com/stnokott/r6api/constants"
+)
+
+var (
+	// ErrTabStatsNotFound is returned if tabstats does not know the requested profile.
+	ErrTabStatsNotFound = errors.New("profile not found on tabstats")
+	// ErrTabStatsRateLimited is returned if tabstats rejected the request due to rate limiting.
+	ErrTabStatsRateLimited = errors.New("tabstats rate limit exceeded")
+	// ErrTabStatsUnavailable is returned if tabstats responded with a server error or an unexpected response.
+	ErrTabStatsUnavailable = errors.New("tabstats unavailable")
+)
+
+// TabStatsError describes a failed tabstats request. It wraps one of the ErrTabStats* errors.
+type TabStatsError struct {
+	StatusCode int
+	// RetryAfter is set from the Retry-After header of rate limited responses, if present.
+	RetryAfter time.Duration
+	Err        error
+	msg        string
+}
+
+func (e *TabStatsError) Error() string {
+	if e.msg == "" {
+		return fmt.Sprintf("%v (status %d)", e.Err, e.StatusCode)
+	}
+	return fmt.Sprintf("%v (status %d): %s", e.Err, e.StatusCode, e.msg)
+}
+
+func (e *TabStatsError) Unwrap() error {
+	return e.Err
+}
+
+// TabStatsClient queries ranked stats from tabstats.
+// Responses are cached per profile until ResetCache is called, so the expensive
+// update request is only sent once per run.
+type TabStatsClient struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	cache map[string]*rankedTabStats
+}
+
+// NewTabStatsClient creates a client for the tabstats API located at baseURL.
+func NewTabStatsClient(baseURL string, timeout time.Duration) *TabStatsClient {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &TabStatsClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		cache: map[string]*rankedTabStats{},
+	}
+}
+
+// ResetCache discards all cached responses. Should be called at the start of each run.
+func (c *TabStatsClient) ResetCache() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = map[string]*rankedTabStats{}
+}
+
+func (c *TabStatsClient) getRankedTabStats(profile *r6api.Profile) (*rankedTabStats, error) {
+	c.mu.Lock()
+	cached, ok := c.cache[profile.ProfileID]
+	c.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	result, err := c.requestRankedTabStats(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cache[profile.ProfileID] = result
+	c.mu.Unlock()
+	return result, nil
+}
+
+func (c *TabStatsClient) requestRankedTabStats(profile *r6api.Profile) (result *rankedTabStats, err error) {
+	requestURL := c.baseURL + url.PathEscape(profile.ProfileID) + "?update=true"
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create tabstats request: %w", err)
+	}
+	req.Header.Add("User-Agent", constants.USER_AGENT)
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &TabStatsError{Err: ErrTabStatsUnavailable, msg: err.Error()}
+	}
+	defer func() {
+		innerErr := resp.Body.Close()
+		if err == nil {
+			err = innerErr
+		}
+	}()
+
+	if err = checkTabStatsResponse(resp); err != nil {
+		return nil, err
+	}
+
+	result = new(rankedTabStats)
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, &TabStatsError{StatusCode: resp.StatusCode, Err: ErrTabStatsUnavailable, msg: "invalid JSON: " + err.Error()}
+	}
+	return result, nil
+}
+
+// checkTabStatsResponse validates status code and content type of a tabstats response.
+func checkTabStatsResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return &TabStatsError{StatusCode: resp.StatusCode, Err: ErrTabStatsNotFound}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e := &TabStatsError{StatusCode: resp.StatusCode, Err: ErrTabStatsRateLimited}
+		e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return e
+	case resp.StatusCode != http.StatusOK:
+		return &TabStatsError{StatusCode: resp.StatusCode, Err: ErrTabStatsUnavailable, msg: responseSnippet(resp.Body)}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return &TabStatsError{
+			StatusCode: resp.StatusCode,
+			Err:        ErrTabStatsUnavailable,
+			msg:        fmt.Sprintf("unexpected content type '%s'", resp.Header.Get("Content-Type")),
+		}
+	}
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of seconds or an HTTP date.
+// It returns 0 if the value is missing, invalid or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// responseSnippet reads the beginning of an error response body for logging.
+func responseSnippet(body io.Reader) string {
+	b, _ := io.ReadAll(io.LimitReader(body, 200))
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stnokott/r6api"
+)
+
+func TestTabStatsClientErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		wantErr     error
+		wantStatus  int
+		wantRetry   time.Duration
+		wantSuccess bool
+	}{
+		{
+			name: "ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				_, _ = w.Write([]byte("{}"))
+			},
+			wantSuccess: true,
+		},
+		{
+			name:       "not found",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			wantErr:    ErrTabStatsNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "30")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantErr:    ErrTabStatsRateLimited,
+			wantStatus: http.StatusTooManyRequests,
+			wantRetry:  30 * time.Second,
+		},
+		{
+			name: "rate limited without retry after",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantErr:    ErrTabStatsRateLimited,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name: "upstream down",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			},
+			wantErr:    ErrTabStatsUnavailable,
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name: "wrong content type",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = w.Write([]byte("<html></html>"))
+			},
+			wantErr:    ErrTabStatsUnavailable,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "invalid json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte("{"))
+			},
+			wantErr:    ErrTabStatsUnavailable,
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			c := NewTabStatsClient(server.URL, time.Second)
+			_, err := c.getRankedTabStats(&r6api.Profile{ProfileID: "profile"})
+			if tt.wantSuccess {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var tabErr *TabStatsError
+			if !errors.As(err, &tabErr) {
+				t.Fatalf("error %v is no TabStatsError", err)
+			}
+			if tabErr.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", tabErr.StatusCode, tt.wantStatus)
+			}
+			if tabErr.RetryAfter != tt.wantRetry {
+				t.Errorf("retry after = %v, want %v", tabErr.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestTabStatsClientUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	c := NewTabStatsClient(server.URL, time.Second)
+	if _, err := c.getRankedTabStats(&r6api.Profile{ProfileID: "profile"}); !errors.Is(err, ErrTabStatsUnavailable) {
+		t.Errorf("error = %v, want %v", err, ErrTabStatsUnavailable)
+	}
+}
+
+func TestTabStatsClientCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/profiles/profile" || r.URL.Query().Get("update") != "true" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := NewTabStatsClient(server.URL+"/profiles", time.Second)
+	profile := &r6api.Profile{ProfileID: "profile"}
+	for i := 0; i < 3; i++ {
+		if _, err := c.getRankedTabStats(profile); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("sent %d requests within one run, want 1", n)
+	}
+
+	c.ResetCache()
+	if _, err := c.getRankedTabStats(profile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("sent %d requests after cache reset, want 2", n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{"-1", 0},
+		{"1.5", 0},
+		{"5s", 0},
+		{"Tue, 14 Nov 2023 12:01:00 GMT", time.Minute},
+		{"Tue, 14 Nov 2023 11:59:00 GMT", 0},
+		{"invalid", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package migrate rewrites data in an InfluxDB bucket written with an older schema version or with previous measurement names to the current ones.
+// Renamed tag keys are not migrated, see Renames.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/rs/zerolog"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// MarkerMeasurement is the measurement recording the schema version a bucket was migrated to.
+const MarkerMeasurement = "schema_migrations"
+
+const markerField = "version"
+
+// Target is the bucket which is migrated.
+type Target struct {
+	Org    string
+	Bucket string
+	// Rewriter is used to find the written names of measurements and tags.
+	Rewriter *metrics.Rewriter
+}
+
+// Action is a single change to the data in a bucket.
+type Action interface {
+	// Flux returns the query writing the migrated data.
+	Flux(t Target) string
+	// DeletePredicate returns the predicate of the data to delete after the query succeeded, empty if nothing needs to be deleted.
+	DeletePredicate(t Target) string
+}
+
+// Step migrates data from the previous schema version to To.
+type Step struct {
+	To          int
+	Description string
+	Actions     []Action
+}
+
+// Migrator runs the steps required to bring a bucket to the current schema version.
+type Migrator struct {
+	client influxdb2.Client
+	target Target
+	logger *zerolog.Logger
+}
+
+// New creates a migrator for the given bucket.
+func New(client influxdb2.Client, target Target, logger *zerolog.Logger) *Migrator {
+	return &Migrator{
+		client: client,
+		target: target,
+		logger: logger,
+	}
+}
+
+// Version returns the schema version the bucket was migrated to.
+// Buckets without migration marker contain data of version 1, the only version without schema_version field.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	flux := fmt.Sprintf(`from(bucket: %s)
+  |> range(start: 0)
+  |> filter(fn: (r) => r._measurement == %s and r._field == %s)
+  |> group()
+  |> max()`, quote(m.target.Bucket), quote(m.target.Rewriter.Measurement(MarkerMeasurement)), quote(markerField))
+	result, err := m.client.QueryAPI(m.target.Org).Query(ctx, flux)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+
+	version := 1
+	for result.Next() {
+		if v, ok := result.Record().Value().(int64); ok && int(v) > version {
+			version = int(v)
+		}
+	}
+	return version, result.Err()
+}
+
+// Pending returns the steps required to migrate from the given version to metrics.SchemaVersion.
+func Pending(from int) []Step {
+	var pending []Step
+	for _, step := range Steps {
+		if step.To > from && step.To <= metrics.SchemaVersion {
+			pending = append(pending, step)
+		}
+	}
+	return pending
+}
+
+// Run migrates the bucket from the given version. If dryRun is true, the queries and deletions are printed to out instead.
+func (m *Migrator) Run(ctx context.Context, from int, dryRun bool, out io.Writer) error {
+	steps := Pending(from)
+	if len(steps) == 0 {
+		m.logger.Info().Int("version", from).Msg("bucket is up to date")
+		return nil
+	}
+	for _, step := range steps {
+		m.logger.Info().Int("to", step.To).Str("description", step.Description).Msg("migrating")
+		if err := m.apply(ctx, step.Actions, dryRun, out); err != nil {
+			return fmt.Errorf("migration to version %d failed: %w", step.To, err)
+		}
+		if dryRun {
+			continue
+		}
+		if err := m.mark(ctx, step.To); err != nil {
+			return fmt.Errorf("could not record migration to version %d: %w", step.To, err)
+		}
+	}
+	return nil
+}
+
+// Rename moves the measurements written with the previous rewriter to the names written by the target's rewriter.
+// It needs to run before Version and Run, which only find the data under its current names.
+// Tag keys are left unchanged.
+func (m *Migrator) Rename(ctx context.Context, previous *metrics.Rewriter, dryRun bool, out io.Writer) error {
+	actions := Renames(previous, m.target, metrics.CurrentSchema())
+	if len(actions) == 0 {
+		m.logger.Info().Msg("measurement names are unchanged")
+		return nil
+	}
+	m.logger.Info().Int("measurements", len(actions)).Msg("renaming measurements")
+	if err := m.apply(ctx, actions, dryRun, out); err != nil {
+		return fmt.Errorf("renaming measurements failed: %w", err)
+	}
+	return nil
+}
+
+// apply runs the actions in order, or prints them to out if dryRun is true.
+func (m *Migrator) apply(ctx context.Context, actions []Action, dryRun bool, out io.Writer) error {
+	for _, action := range actions {
+		flux, predicate := action.Flux(m.target), action.DeletePredicate(m.target)
+		if dryRun {
+			fmt.Fprintf(out, "%s\n\n", flux)
+			if predicate != "" {
+				fmt.Fprintf(out, "delete: %s\n\n", predicate)
+			}
+			continue
+		}
+		if err := m.run(ctx, flux, predicate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, flux string, predicate string) error {
+	result, err := m.client.QueryAPI(m.target.Org).Query(ctx, flux)
+	if err != nil {
+		return err
+	}
+	var rewritten int64
+	for result.Next() {
+		if n, ok := result.Record().Value().(int64); ok {
+			rewritten += n
+		}
+	}
+	if err := result.Err(); err != nil {
+		result.Close()
+		return err
+	}
+	result.Close()
+	m.logger.Info().Int64("points", rewritten).Msg("rewrote points")
+
+	if predicate == "" {
+		return nil
+	}
+	if err := m.client.DeleteAPI().DeleteWithName(ctx, m.target.Org, m.target.Bucket, time.Unix(0, 0), time.Now(), predicate); err != nil {
+		return fmt.Errorf("could not delete migrated data: %w", err)
+	}
+	m.logger.Info().Str("predicate", predicate).Msg("deleted migrated data")
+	return nil
+}
+
+func (m *Migrator) mark(ctx context.Context, version int) error {
+	p := influxdb2.NewPoint(
+		m.target.Rewriter.Measurement(MarkerMeasurement),
+		nil,
+		map[string]interface{}{markerField: version},
+		time.Now(),
+	)
+	return m.client.WriteAPIBlocking(m.target.Org, m.target.Bucket).WritePoint(ctx, p)
+}
+
+// quote returns s as Flux string literal.
+func quote(s string) string {
+	return strconv.Quote(s)
+}
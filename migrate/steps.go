@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// Steps lists all migrations in ascending order. Every increase of metrics.SchemaVersion needs a step.
+var Steps = []Step{
+	{
+		To:          2,
+		Description: "add schema_version field",
+		Actions: []Action{
+			StampVersion{Measurement: "maps", KeyField: "matches_played", Version: 2},
+			StampVersion{Measurement: "bombsites", KeyField: "rounds_played", Version: 2},
+			StampVersion{Measurement: "actions", KeyField: "rounds_played", Version: 2},
+			StampVersion{Measurement: "matches", KeyField: "matches_played", Version: 2},
+			StampVersion{Measurement: "ranked", KeyField: "mmr", Version: 2},
+			StampVersion{Measurement: "ranked_tabstats", KeyField: "mmr", Version: 2},
+		},
+	},
+}
+
+// countRewritten is appended to queries writing data to report the number of written points.
+const countRewritten = `
+  |> group()
+  |> count()`
+
+// StampVersion adds the schema version field to all points of a measurement.
+// KeyField needs to be present on every point, its timestamps and tags are used for the new field.
+type StampVersion struct {
+	Measurement string
+	KeyField    string
+	Version     int
+}
+
+func (s StampVersion) Flux(t Target) string {
+	return fmt.Sprintf(`from(bucket: %s)
+  |> range(start: 0)
+  |> filter(fn: (r) => r._measurement == %s and r._field == %s)
+  |> map(fn: (r) => ({r with _field: %s, _value: %d}))
+  |> to(bucket: %s, org: %s)`+countRewritten,
+		quote(t.Bucket),
+		quote(t.Rewriter.Measurement(s.Measurement)), quote(s.KeyField),
+		quote(metrics.SchemaVersionField), s.Version,
+		quote(t.Bucket), quote(t.Org),
+	)
+}
+
+func (s StampVersion) DeletePredicate(Target) string {
+	return ""
+}
+
+// RenameMeasurement copies all points of a measurement to a new measurement and deletes the old one.
+// From and To are the names as written, as renames are caused by changed rewrites.
+type RenameMeasurement struct {
+	From string
+	To   string
+}
+
+func (r RenameMeasurement) Flux(t Target) string {
+	return fmt.Sprintf(`from(bucket: %s)
+  |> range(start: 0)
+  |> filter(fn: (r) => r._measurement == %s)
+  |> set(key: "_measurement", value: %s)
+  |> to(bucket: %s, org: %s)`+countRewritten,
+		quote(t.Bucket),
+		quote(r.From), quote(r.To),
+		quote(t.Bucket), quote(t.Org),
+	)
+}
+
+func (r RenameMeasurement) DeletePredicate(Target) string {
+	return fmt.Sprintf(`_measurement=%s`, quote(r.From))
+}
+
+// Renames returns the actions moving all measurements of the schema and the migration marker from the names
+// written by previous to the names written by the target's rewriter.
+//
+// Only measurement names are migrated. Tags renamed by TAG_RENAMES are not: delete predicates cannot select points
+// by tag key, so the points with the previous tag keys could not be removed after copying them. Data written with
+// other tag keys keeps them and needs to be queried with both keys.
+func Renames(previous *metrics.Rewriter, t Target, schema metrics.Schema) []Action {
+	names := []string{MarkerMeasurement}
+	for _, m := range schema.Measurements {
+		names = append(names, m.Name)
+	}
+	var actions []Action
+	for _, name := range names {
+		from, to := previous.Measurement(name), t.Rewriter.Measurement(name)
+		if from != to {
+			actions = append(actions, RenameMeasurement{From: from, To: to})
+		}
+	}
+	return actions
+}
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stnokott/r6prom/metrics"
+)
+
+func TestRenames(t *testing.T) {
+	schema := metrics.Schema{Measurements: []metrics.MeasurementSchema{{Name: "maps"}, {Name: "actions"}}}
+	tests := []struct {
+		name     string
+		previous *metrics.Rewriter
+		current  *metrics.Rewriter
+		want     []Action
+	}{
+		{"unchanged", metrics.NewRewriter("r6_", nil, nil, nil), metrics.NewRewriter("r6_", nil, nil, nil), nil},
+		// tag keys are not migrated
+		{"renamed tag", metrics.NewRewriter("", nil, nil, nil), metrics.NewRewriter("", nil, map[string]string{"username": "player"}, nil), nil},
+		{
+			"renamed measurement",
+			metrics.NewRewriter("", nil, nil, nil),
+			metrics.NewRewriter("", map[string]string{"actions": "operators"}, nil, nil),
+			[]Action{RenameMeasurement{From: "actions", To: "operators"}},
+		},
+		{
+			"changed prefix",
+			metrics.NewRewriter("r6_", map[string]string{"actions": "operators"}, nil, nil),
+			metrics.NewRewriter("", map[string]string{"actions": "operators"}, nil, nil),
+			[]Action{
+				RenameMeasurement{From: "r6_" + MarkerMeasurement, To: MarkerMeasurement},
+				RenameMeasurement{From: "r6_maps", To: "maps"},
+				RenameMeasurement{From: "r6_operators", To: "operators"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Renames(tt.previous, Target{Org: "org", Bucket: "bucket", Rewriter: tt.current}, schema)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Renames() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameMeasurement(t *testing.T) {
+	// the target's rewriter is not applied to the written names
+	target := Target{Org: "org", Bucket: "bucket", Rewriter: metrics.NewRewriter("r6_", nil, nil, nil)}
+	r := RenameMeasurement{From: "actions", To: "operators"}
+
+	flux := r.Flux(target)
+	for _, want := range []string{`r._measurement == "actions"`, `set(key: "_measurement", value: "operators")`, `to(bucket: "bucket", org: "org")`} {
+		if !strings.Contains(flux, want) {
+			t.Errorf("query does not contain %s:\n%s", want, flux)
+		}
+	}
+	if got := r.DeletePredicate(target); got != `_measurement="actions"` {
+		t.Errorf("DeletePredicate() = %s", got)
+	}
+}
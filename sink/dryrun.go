@@ -0,0 +1,129 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// Dry-run output formats
+const (
+	FormatLineProtocol = "lp"
+	FormatJSON         = "json"
+)
+
+// DryRun prints points instead of writing them. Points are collected until Flush, then printed
+// sorted by measurement, tags and time, followed by the number of points per measurement.
+type DryRun struct {
+	errorReporter
+	out    io.Writer
+	format string
+
+	mu      sync.Mutex
+	pending []*write.Point
+}
+
+// NewDryRun creates a dry-run sink printing to out in the given format.
+func NewDryRun(out io.Writer, format string) (*DryRun, error) {
+	if format != FormatLineProtocol && format != FormatJSON {
+		return nil, fmt.Errorf("unsupported dry-run format '%s', expected %s or %s", format, FormatLineProtocol, FormatJSON)
+	}
+	return &DryRun{
+		errorReporter: newErrorReporter(),
+		out:           out,
+		format:        format,
+	}, nil
+}
+
+func (d *DryRun) WritePoint(p *write.Point) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = append(d.pending, p)
+}
+
+// Flush prints all pending points and the summary, which is printed with a total of 0 if no points were written.
+func (d *DryRun) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := make([]string, len(d.pending))
+	for i, p := range d.pending {
+		lines[i] = write.PointToLineProtocol(p, Precision)
+	}
+	order := make([]int, len(d.pending))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := d.pending[order[i]], d.pending[order[j]]
+		if a.Name() != b.Name() {
+			return a.Name() < b.Name()
+		}
+		if ta, tb := tagKey(a), tagKey(b); ta != tb {
+			return ta < tb
+		}
+		if !a.Time().Equal(b.Time()) {
+			return a.Time().Before(b.Time())
+		}
+		return lines[order[i]] < lines[order[j]]
+	})
+
+	var sb strings.Builder
+	counts := map[string]int{}
+	for _, i := range order {
+		p := d.pending[i]
+		counts[p.Name()]++
+		if d.format == FormatLineProtocol {
+			sb.WriteString(lines[i])
+			continue
+		}
+		b, err := json.MarshalIndent(jsonPoint{
+			Measurement: p.Name(),
+			Tags:        metrics.PointTags(p),
+			Fields:      metrics.PointFields(p),
+			Time:        p.Time(),
+		}, "", "  ")
+		if err != nil {
+			d.report(fmt.Errorf("could not render point: %w", err))
+			continue
+		}
+		sb.Write(b)
+		sb.WriteByte('\n')
+	}
+	d.pending = nil
+
+	measurements := make([]string, 0, len(counts))
+	for m := range counts {
+		measurements = append(measurements, m)
+	}
+	sort.Strings(measurements)
+	// summary lines are comments in line protocol
+	total := 0
+	for _, m := range measurements {
+		fmt.Fprintf(&sb, "# %s: %d points\n", m, counts[m])
+		total += counts[m]
+	}
+	fmt.Fprintf(&sb, "# total: %d points\n", total)
+
+	if _, err := io.WriteString(d.out, sb.String()); err != nil {
+		d.report(err)
+	}
+}
+
+// tagKey returns the sorted tags of p as a comparable string.
+func tagKey(p *write.Point) string {
+	var sb strings.Builder
+	for _, tag := range p.TagList() {
+		sb.WriteString(tag.Key)
+		sb.WriteByte('=')
+		sb.WriteString(tag.Value)
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
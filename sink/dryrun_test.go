@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+func TestDryRunLineProtocol(t *testing.T) {
+	var out bytes.Buffer
+	d, err := NewDryRun(&out, FormatLineProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1 := time.Unix(1700000000, 0)
+	t2 := t1.Add(time.Minute)
+
+	// points are printed sorted by measurement, tags and time, regardless of the write order
+	d.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "bob"}, map[string]interface{}{"mmr": 2500}, t1))
+	d.WritePoint(influxdb2.NewPoint("maps", map[string]string{"username": "alice", "map": "Villa"}, map[string]interface{}{"kills": 2}, t2))
+	d.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "alice"}, map[string]interface{}{"mmr": 3100}, t2))
+	d.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "alice"}, map[string]interface{}{"mmr": 3000}, t1))
+	d.WritePoint(influxdb2.NewPoint("maps", map[string]string{"username": "alice", "map": "Oregon"}, map[string]interface{}{"kills": 1, "kd_ratio": 0.5}, t2))
+	d.Flush()
+
+	want := `maps,map=Oregon,username=alice kd_ratio=0.5,kills=1i 1700000060
+maps,map=Villa,username=alice kills=2i 1700000060
+ranked,username=alice mmr=3000i 1700000000
+ranked,username=alice mmr=3100i 1700000060
+ranked,username=bob mmr=2500i 1700000000
+# maps: 2 points
+# ranked: 3 points
+# total: 5 points
+`
+	if got := out.String(); got != want {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+
+	// an empty run still prints the summary
+	out.Reset()
+	d.Flush()
+	if got := out.String(); got != "# total: 0 points\n" {
+		t.Errorf("empty output = %q, want the summary", got)
+	}
+	checkNoSinkError(t, d)
+}
+
+func TestDryRunJSON(t *testing.T) {
+	var out bytes.Buffer
+	d, err := NewDryRun(&out, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "alice"}, map[string]interface{}{"mmr": 3000, "rank_name": "Gold 2"}, time.Unix(1700000000, 0).UTC()))
+	d.Flush()
+
+	want := `{
+  "measurement": "ranked",
+  "tags": {
+    "username": "alice"
+  },
+  "fields": {
+    "mmr": 3000,
+    "rank_name": "Gold 2"
+  },
+  "time": "2023-11-14T22:13:20Z"
+}
+# ranked: 1 points
+# total: 1 points
+`
+	if got := out.String(); got != want {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+	checkNoSinkError(t, d)
+}
+
+func TestDryRunFormat(t *testing.T) {
+	if _, err := NewDryRun(&bytes.Buffer{}, "csv"); err == nil || !strings.Contains(err.Error(), "csv") {
+		t.Errorf("NewDryRun() error = %v, want unsupported format", err)
+	}
+}
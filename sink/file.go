@@ -0,0 +1,232 @@
+package sink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// File formats
+const (
+	FormatJSONLines = "jsonl"
+	FormatCSV       = "csv"
+)
+
+// jsonLinesPrefix is the file name prefix of JSON Lines files, which contain points of all measurements.
+const jsonLinesPrefix = "points"
+
+// jsonPoint is the JSON representation of a point.
+type jsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Time        time.Time              `json:"time"`
+}
+
+// csvFile is the current CSV file of a single measurement.
+type csvFile struct {
+	file *rotatingFile
+	// tags and fields are the sorted column names in the header of the current file.
+	// They start with the columns of the measurement schema and are only extended by columns missing from it.
+	tags   []string
+	fields []string
+}
+
+// newCSVFile creates the CSV file of a measurement with the columns listed in its schema.
+func newCSVFile(file *rotatingFile, schema metrics.MeasurementSchema) *csvFile {
+	c := &csvFile{
+		file:   file,
+		tags:   append([]string(nil), schema.Tags...),
+		fields: make([]string, 0, len(schema.Fields)),
+	}
+	for _, field := range schema.Fields {
+		c.fields = append(c.fields, field.Name)
+	}
+	sort.Strings(c.tags)
+	sort.Strings(c.fields)
+	return c
+}
+
+// File writes points to files on disk, either all points into JSON Lines files or
+// into one CSV file per measurement.
+// Files are rotated by the time of the written points, not the time they are flushed.
+type File struct {
+	errorReporter
+	dir    string
+	format string
+	rotate RotateOpts
+	schema metrics.Schema
+
+	mu      sync.Mutex
+	pending []*write.Point
+	jsonl   *rotatingFile
+	csv     map[string]*csvFile
+}
+
+// NewFile creates a file sink writing to dir in the given format.
+// The CSV header of a measurement contains all tags and fields listed for it in schema,
+// so it does not change with the fields present in individual points.
+func NewFile(dir string, format string, rotate RotateOpts, schema metrics.Schema) (*File, error) {
+	if format != FormatJSONLines && format != FormatCSV {
+		return nil, fmt.Errorf("unsupported file format '%s', expected %s or %s", format, FormatJSONLines, FormatCSV)
+	}
+	return &File{
+		errorReporter: newErrorReporter(),
+		dir:           dir,
+		format:        format,
+		rotate:        rotate,
+		schema:        schema,
+		jsonl:         newRotatingFile(dir, jsonLinesPrefix, FormatJSONLines, rotate),
+		csv:           map[string]*csvFile{},
+	}, nil
+}
+
+func (f *File) WritePoint(p *write.Point) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending = append(f.pending, p)
+}
+
+// Flush writes all pending points and flushes the files to disk.
+func (f *File) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, p := range f.pending {
+		var err error
+		if f.format == FormatCSV {
+			err = f.writeCSV(p)
+		} else {
+			err = f.writeJSONLines(p)
+		}
+		if err != nil {
+			f.report(fmt.Errorf("could not write point to file: %w", err))
+		}
+	}
+	f.pending = nil
+
+	if err := f.jsonl.flush(); err != nil {
+		f.report(err)
+	}
+	for _, c := range f.csv {
+		if err := c.file.flush(); err != nil {
+			f.report(err)
+		}
+	}
+}
+
+func (f *File) writeJSONLines(p *write.Point) error {
+	if f.jsonl.needsRotation(p.Time()) {
+		if err := f.jsonl.open(p.Time()); err != nil {
+			return err
+		}
+	}
+	b, err := json.Marshal(jsonPoint{
+		Measurement: p.Name(),
+		Tags:        metrics.PointTags(p),
+		Fields:      metrics.PointFields(p),
+		Time:        p.Time(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.jsonl.Write(append(b, '\n'))
+	return err
+}
+
+func (f *File) writeCSV(p *write.Point) error {
+	c, ok := f.csv[p.Name()]
+	if !ok {
+		schema, _ := f.schema.Measurement(p.Name())
+		c = newCSVFile(newRotatingFile(f.dir, p.Name(), FormatCSV, f.rotate), schema)
+		f.csv[p.Name()] = c
+	}
+
+	tags, fields := metrics.PointTags(p), metrics.PointFields(p)
+	newTags, newFields := mergeColumns(c.tags, tags), mergeColumns(c.fields, fields)
+	columnsChanged := len(newTags) != len(c.tags) || len(newFields) != len(c.fields)
+
+	if columnsChanged || c.file.needsRotation(p.Time()) {
+		// columns missing from the schema require a new file with extended header
+		c.tags, c.fields = newTags, newFields
+		if err := c.file.open(p.Time()); err != nil {
+			return err
+		}
+		header := append(append([]string{"time"}, c.tags...), c.fields...)
+		if err := writeCSVRecord(c.file, header); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, 0, 1+len(c.tags)+len(c.fields))
+	record = append(record, p.Time().UTC().Format(time.RFC3339))
+	for _, key := range c.tags {
+		record = append(record, tags[key])
+	}
+	for _, key := range c.fields {
+		record = append(record, formatValue(fields[key]))
+	}
+	return writeCSVRecord(c.file, record)
+}
+
+func writeCSVRecord(file *rotatingFile, record []string) error {
+	w := csv.NewWriter(file)
+	if err := w.Write(record); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// mergeColumns returns the sorted union of columns and the keys of values.
+func mergeColumns[V any](columns []string, values map[string]V) []string {
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		known[c] = true
+	}
+	merged := columns
+	for key := range values {
+		if !known[key] {
+			merged = append(merged, key)
+		}
+	}
+	if len(merged) == len(columns) {
+		return columns
+	}
+	result := make([]string, len(merged))
+	copy(result, merged)
+	sort.Strings(result)
+	return result
+}
+
+// formatValue formats a field value as plain string.
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Close closes all open files.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.jsonl.close()
+	for _, c := range f.csv {
+		if closeErr := c.file.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+func TestFileCSVHeaderAndRotation(t *testing.T) {
+	dir := t.TempDir()
+	schema := metrics.Schema{Measurements: []metrics.MeasurementSchema{{
+		Name:   "maps",
+		Tags:   []string{"username", "map"},
+		Fields: []metrics.FieldSchema{{Name: "kills", Type: metrics.FieldTypeInteger}, {Name: "deaths", Type: metrics.FieldTypeInteger}},
+	}}}
+	f, err := NewFile(dir, FormatCSV, RotateOpts{Daily: true}, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day1 := time.Date(2023, 11, 14, 23, 0, 0, 0, time.Local)
+	day2 := day1.Add(2 * time.Hour)
+	f.WritePoint(influxdb2.NewPoint("maps", map[string]string{"username": "a", "map": "Oregon"}, map[string]interface{}{"kills": 1}, day1))
+	f.WritePoint(influxdb2.NewPoint("maps", map[string]string{"username": "a", "map": "Villa"}, map[string]interface{}{"deaths": 2}, day1))
+	f.Flush()
+	f.WritePoint(influxdb2.NewPoint("maps", map[string]string{"username": "a", "map": "Oregon"}, map[string]interface{}{"kills": 3}, day2))
+	f.Flush()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-f.Errors():
+		t.Fatal(err)
+	default:
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.csv"))
+	sort.Strings(files)
+	want := map[string]string{
+		"maps-" + day1.Format(dayLayout) + ".0.csv": "time,map,username,deaths,kills\n" +
+			day1.UTC().Format(time.RFC3339) + ",Oregon,a,,1\n" +
+			day1.UTC().Format(time.RFC3339) + ",Villa,a,2,\n",
+		"maps-" + day2.Format(dayLayout) + ".0.csv": "time,map,username,deaths,kills\n" +
+			day2.UTC().Format(time.RFC3339) + ",Oregon,a,,3\n",
+	}
+	if len(files) != len(want) {
+		t.Fatalf("files = %v, want %d files", files, len(want))
+	}
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(b); got != want[filepath.Base(path)] {
+			t.Errorf("%s:\n%s\nwant:\n%s", filepath.Base(path), got, want[filepath.Base(path)])
+		}
+	}
+}
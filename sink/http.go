@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// DefaultBatchSize is the maximum number of points sent in a single write request.
+const DefaultBatchSize = 10000
+
+// DefaultHTTPTimeout limits the duration of a single write request.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// maxErrorBodySize limits how much of an error response is included in errors.
+const maxErrorBodySize = 1024
+
+// httpEndpoint POSTs request bodies to a single URL, used by all sinks pushing over HTTP.
+type httpEndpoint struct {
+	url      string
+	username string
+	password string
+	// bearerToken is sent as Authorization header if set, taking precedence over basic auth
+	bearerToken string
+	headers     map[string]string
+	client      *http.Client
+}
+
+func newHTTPEndpoint(url string, timeout time.Duration) *httpEndpoint {
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	return &httpEndpoint{
+		url:     url,
+		headers: map[string]string{},
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// parseHTTPURL parses rawURL and ensures it uses http or https.
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid URL '%s', expected http or https", rawURL)
+	}
+	return u, nil
+}
+
+// post sends body with the given content headers and returns an error for non-2xx responses.
+func (e *httpEndpoint) post(body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	switch {
+	case e.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+e.bearerToken)
+	case e.username != "":
+		req.SetBasicAuth(e.username, e.password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// batches calls send for consecutive slices of at most size points and reports errors to r.
+func batches(points []*write.Point, size int, r errorReporter, send func([]*write.Point) error) {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	for start := 0; start < len(points); start += size {
+		end := start + size
+		if end > len(points) {
+			end = len(points)
+		}
+		if err := send(points[start:end]); err != nil {
+			r.report(fmt.Errorf("could not write %d points: %w", end-start, err))
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package sink
+
+import (
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	influxlog "github.com/influxdata/influxdb-client-go/v2/log"
+	"github.com/stnokott/r6prom/constants"
+)
+
+// Precision is the timestamp precision of all points written as line protocol.
+// Points are collected once per run, so seconds are precise enough.
+const Precision = time.Second
+
+// InfluxOptions returns the client options of all sinks writing through the InfluxDB client.
+// The precision is used by the client for both encoding the points and the precision parameter.
+func InfluxOptions() *influxdb2.Options {
+	return influxdb2.DefaultOptions().
+		SetBatchSize(DefaultBatchSize). // high batch size to allow for manual flushing
+		SetApplicationName(constants.NAME).
+		SetLogLevel(influxlog.ErrorLevel).
+		SetPrecision(Precision)
+}
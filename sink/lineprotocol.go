@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+)
+
+// LineProtocolOpts configures a LineProtocol sink.
+type LineProtocolOpts struct {
+	// URL is the server URL, e.g. "http://localhost:8086" for InfluxDB 1.8+.
+	URL string
+	// Database and RetentionPolicy select the InfluxDB 1.x database and retention policy.
+	Database        string
+	RetentionPolicy string
+	// Username and Password authenticate against InfluxDB 1.x if Username is set.
+	Username string
+	Password string
+	// BatchSize is the maximum number of points per request, DefaultBatchSize if 0.
+	BatchSize int
+	// Timeout limits each request, DefaultHTTPTimeout if 0.
+	Timeout time.Duration
+}
+
+// LineProtocol writes points as line protocol to the InfluxDB 1.x compatible /api/v2/write endpoint, which is
+// supported by InfluxDB 1.8+ and other databases accepting line protocol like VictoriaMetrics or QuestDB.
+// Points are written by the InfluxDB client, so batching, retries and error reporting work like for InfluxDB 2.x.
+type LineProtocol struct {
+	api.WriteAPI
+	client influxdb2.Client
+}
+
+// NewLineProtocol creates a line protocol sink.
+func NewLineProtocol(opts LineProtocolOpts) (*LineProtocol, error) {
+	if _, err := parseHTTPURL(opts.URL); err != nil {
+		return nil, err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	options := InfluxOptions().SetHTTPRequestTimeout(uint(timeout.Seconds()))
+	if opts.BatchSize > 0 {
+		options.SetBatchSize(uint(opts.BatchSize))
+	}
+
+	// the 1.x compatibility API expects "username:password" as token and "database/retention-policy" as bucket
+	var token string
+	if opts.Username != "" {
+		token = opts.Username + ":" + opts.Password
+	}
+	bucket := opts.Database
+	if opts.RetentionPolicy != "" {
+		bucket += "/" + opts.RetentionPolicy
+	}
+
+	client := influxdb2.NewClientWithOptions(opts.URL, token, options)
+	return &LineProtocol{
+		WriteAPI: client.WriteAPI("", bucket),
+		client:   client,
+	}, nil
+}
+
+// Close writes the pending points and releases the client.
+func (l *LineProtocol) Close() {
+	l.client.Close()
+}
@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+type lineProtocolRequest struct {
+	path  string
+	query string
+	auth  string
+	body  string
+}
+
+func TestLineProtocol(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusNoContent)
+	requests := make(chan lineProtocolRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- lineProtocolRequest{r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), string(body)}
+		w.WriteHeader(int(status.Load()))
+		if status.Load() >= 300 {
+			_, _ = w.Write([]byte(`{"code":"not found","message":"database not found: \"r6\""}`))
+		}
+	}))
+	defer server.Close()
+
+	l, err := NewLineProtocol(LineProtocolOpts{
+		URL:             server.URL,
+		Database:        "r6",
+		RetentionPolicy: "autogen",
+		Username:        "user",
+		Password:        "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	errs := l.Errors()
+
+	// timestamps are written with the precision of the precision parameter
+	ts := time.Unix(1700000000, 123456789)
+	l.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "alice"}, map[string]interface{}{"mmr": 3000}, ts))
+	l.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "bob"}, map[string]interface{}{"mmr": 2500}, ts))
+	l.Flush()
+
+	req := receiveRequest(t, requests)
+	if req.path != "/api/v2/write" {
+		t.Errorf("path = %s, want the 1.x compatible write endpoint", req.path)
+	}
+	for _, param := range []string{"bucket=r6%2Fautogen", "precision=s"} {
+		if !strings.Contains(req.query, param) {
+			t.Errorf("query = %s, want %s", req.query, param)
+		}
+	}
+	if req.auth != "Token user:secret" {
+		t.Errorf("Authorization = %q, want the 1.x credentials", req.auth)
+	}
+	wantBody := "ranked,username=alice mmr=3000i 1700000000\nranked,username=bob mmr=2500i 1700000000\n"
+	if req.body != wantBody {
+		t.Errorf("body =\n%s\nwant\n%s", req.body, wantBody)
+	}
+
+	// rejected points are reported
+	status.Store(http.StatusBadRequest)
+	l.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "alice"}, map[string]interface{}{"mmr": "high"}, ts))
+	l.Flush()
+	receiveRequest(t, requests)
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "database not found") {
+			t.Errorf("error = %v, want the server message", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write error not reported")
+	}
+}
+
+func receiveRequest(t *testing.T, requests <-chan lineProtocolRequest) lineProtocolRequest {
+	t.Helper()
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("no write request received")
+		return lineProtocolRequest{}
+	}
+}
+
+func TestLineProtocolURL(t *testing.T) {
+	if _, err := NewLineProtocol(LineProtocolOpts{URL: "udp://localhost:8089"}); err == nil {
+		t.Error("NewLineProtocol() accepted a non-HTTP URL")
+	}
+}
@@ -0,0 +1,469 @@
+package sink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// MQTT defaults
+const (
+	DefaultMQTTTopicPrefix     = "r6prom"
+	DefaultMQTTClientID        = "r6prom"
+	DefaultMQTTDiscoveryPrefix = "homeassistant"
+	DefaultMQTTRankField       = "ranked.rank"
+	DefaultMQTTTimeout         = 10 * time.Second
+)
+
+// DefaultMQTTFields are published as retained state if no fields are configured.
+var DefaultMQTTFields = []string{"ranked.mmr", "ranked.max_mmr", "ranked.rank"}
+
+// DefaultMQTTEvents are the measurements published to the events topic if none are configured.
+var DefaultMQTTEvents = []string{"season_change", "sessions", "streak_events", "ranked_discrepancy"}
+
+// mqttEventsTopic is the topic below the topic prefix where events are published.
+const mqttEventsTopic = "events"
+
+// rankChangeMeasurement is the measurement name of rank change events.
+const rankChangeMeasurement = "rank_change"
+
+// MQTT QoS levels: state is republished every run, so it may be lost, events are published once and must arrive.
+const (
+	mqttStateQoS byte = 0
+	mqttEventQoS byte = 1
+)
+
+// mqttKeepAlive is the keep alive interval of the broker connection.
+const mqttKeepAlive = 60 * time.Second
+
+// mqttDisconnectQuiesce is the time in milliseconds to wait for outstanding messages when closing.
+const mqttDisconnectQuiesce = 250
+
+// MQTTOpts configures an MQTT sink.
+type MQTTOpts struct {
+	// URL is the broker address, "mqtt://host:1883" or "mqtts://host:8883" for TLS.
+	URL      string
+	ClientID string
+	Username string
+	Password string
+	// CAFile is a PEM file with CA certificates used instead of the system pool.
+	CAFile string
+	// InsecureSkipVerify disables TLS certificate verification.
+	InsecureSkipVerify bool
+	// TopicPrefix is the first level of all topics.
+	TopicPrefix string
+	// Fields lists "measurement.field" entries published to "<prefix>/<user>/<measurement>/<field>".
+	Fields []string
+	// Events lists measurements whose points are published to "<prefix>/events".
+	Events []string
+	// RankField is the "measurement.field" holding the rank ordinal, changes are published as events.
+	// Disabled if empty.
+	RankField string
+	// RankName converts a rank ordinal to a display name for rank change events.
+	RankName func(ordinal int) string
+	// DiscoveryPrefix is the Home Assistant discovery prefix, discovery is disabled if empty.
+	DiscoveryPrefix string
+	// ServiceName is used as device manufacturer in discovery payloads.
+	ServiceName string
+	// TagKeys are the written tag keys, DefaultTagKeys if unset.
+	TagKeys TagKeys
+	// RankStateFile persists the last rank of every user, so rank changes across restarts are detected.
+	// Disabled if empty.
+	RankStateFile string
+	// Timeout limits connecting and publishing each message, DefaultMQTTTimeout if 0.
+	Timeout time.Duration
+}
+
+// MQTT publishes the latest value of selected fields per user to retained topics and events to a separate topic.
+// The connection to the broker is kept open between flushes and reopened by the next flush if it was lost.
+// State is published with QoS 0, events and discovery payloads with QoS 1.
+// Once a season is registered, only points of that season are published as state and compared for rank changes.
+// If a user has multiple points of a measurement in one run, the last one written is published.
+type MQTT struct {
+	errorReporter
+	client  mqtt.Client
+	timeout time.Duration
+	opts    MQTTOpts
+	fields  map[string]bool
+	events  map[string]bool
+
+	mu      sync.Mutex
+	pending []*write.Point
+	// announced contains state topics for which discovery payloads were published
+	announced map[string]bool
+	// ranks holds the last rank per user
+	ranks map[string]mqttRank
+	// season is the slug of the current season, empty if unknown
+	season string
+}
+
+// mqttRank is the persisted last rank of a user.
+type mqttRank struct {
+	// Season is the slug of the season the rank was reached in, ranks are only compared within a season
+	Season string `json:"season"`
+	Rank   int64  `json:"rank"`
+}
+
+// NewMQTT creates an MQTT sink.
+func NewMQTT(opts MQTTOpts) (*MQTT, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT URL: %w", err)
+	}
+	clientID, username, password := opts.ClientID, opts.Username, opts.Password
+	if clientID == "" {
+		clientID = DefaultMQTTClientID
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMQTTTimeout
+	}
+	if u.User != nil && username == "" {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	clientOpts := mqtt.NewClientOptions().
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetCleanSession(true).
+		SetKeepAlive(mqttKeepAlive).
+		SetConnectTimeout(timeout).
+		SetWriteTimeout(timeout).
+		// lost connections are reopened by the next flush
+		SetAutoReconnect(false)
+	port := u.Port()
+	switch u.Scheme {
+	case "mqtt", "tcp":
+		if port == "" {
+			port = "1883"
+		}
+		clientOpts.AddBroker("tcp://" + net.JoinHostPort(u.Hostname(), port))
+	case "mqtts", "ssl", "tls":
+		if port == "" {
+			port = "8883"
+		}
+		tlsConfig := &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: opts.InsecureSkipVerify,
+		}
+		if opts.CAFile != "" {
+			pem, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("CA file contains no certificates")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		clientOpts.AddBroker("ssl://" + net.JoinHostPort(u.Hostname(), port)).SetTLSConfig(tlsConfig)
+	default:
+		return nil, fmt.Errorf("invalid MQTT URL '%s', expected mqtt or mqtts", opts.URL)
+	}
+
+	if opts.TopicPrefix == "" {
+		opts.TopicPrefix = DefaultMQTTTopicPrefix
+	}
+	opts.TagKeys = opts.TagKeys.orDefault()
+	ranks := map[string]mqttRank{}
+	if opts.RankStateFile != "" {
+		if err := loadJSONFile(opts.RankStateFile, &ranks); err != nil {
+			return nil, fmt.Errorf("could not load MQTT rank state: %w", err)
+		}
+	}
+	return &MQTT{
+		errorReporter: newErrorReporter(),
+		client:        mqtt.NewClient(clientOpts),
+		timeout:       timeout,
+		opts:          opts,
+		fields:        toBoolSet(opts.Fields),
+		events:        toBoolSet(opts.Events),
+		announced:     map[string]bool{},
+		ranks:         ranks,
+	}, nil
+}
+
+func (m *MQTT) RegisterSeason(slug string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.season = slug
+}
+
+func toBoolSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+func (m *MQTT) WritePoint(p *write.Point) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = append(m.pending, p)
+}
+
+// mqttMessage is a single message to publish.
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	qos     byte
+	retain  bool
+}
+
+// mqttState is the latest value of a field of a user.
+type mqttState struct {
+	username    string
+	measurement string
+	field       string
+	value       interface{}
+}
+
+// Flush publishes the latest field values and events of all pending points.
+func (m *MQTT) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := m.pending
+	m.pending = nil
+	if len(pending) == 0 {
+		return
+	}
+
+	var states []*mqttState
+	stateIndex := map[string]int{}
+	var events []mqttMessage
+	// ranks holds the last rank per username, only it is compared to the previous run
+	ranks := map[string]rankValue{}
+	for _, p := range pending {
+		tags := metrics.PointTags(p)
+		if m.events[p.Name()] {
+			if msg, err := m.eventMessage(p.Name(), tags, metrics.PointFields(p), p.Time()); err != nil {
+				m.report(err)
+			} else {
+				events = append(events, msg)
+			}
+		}
+		username := tags[m.opts.TagKeys.Username]
+		if username == "" {
+			continue
+		}
+		if slug, ok := tags[m.opts.TagKeys.SeasonSlug]; ok && m.season != "" && slug != m.season {
+			// e.g. past seasons of the ranked history or the final snapshot of the previous season
+			continue
+		}
+		for _, field := range p.FieldList() {
+			qualified := p.Name() + "." + field.Key
+			if qualified == m.opts.RankField {
+				ranks[username] = rankValue{tags: tags, value: field.Value, t: p.Time()}
+			}
+			if !m.fields[qualified] {
+				continue
+			}
+			state := &mqttState{username: username, measurement: p.Name(), field: field.Key, value: field.Value}
+			topic := m.stateTopic(state)
+			if i, ok := stateIndex[topic]; ok {
+				states[i] = state
+			} else {
+				stateIndex[topic] = len(states)
+				states = append(states, state)
+			}
+		}
+	}
+
+	for username, rank := range ranks {
+		if msg, ok := m.rankChange(username, rank); ok {
+			events = append(events, msg)
+		}
+	}
+	if len(ranks) > 0 && m.opts.RankStateFile != "" {
+		if err := saveJSONFile(m.opts.RankStateFile, m.ranks); err != nil {
+			m.report(fmt.Errorf("could not save MQTT rank state: %w", err))
+		}
+	}
+
+	var messages []mqttMessage
+	var announce []string
+	for _, state := range states {
+		topic := m.stateTopic(state)
+		if m.opts.DiscoveryPrefix != "" && !m.announced[topic] {
+			msg, err := m.discoveryMessage(state, topic)
+			if err != nil {
+				m.report(err)
+			} else {
+				messages = append(messages, msg)
+				announce = append(announce, topic)
+			}
+		}
+		messages = append(messages, mqttMessage{topic: topic, payload: []byte(formatValue(state.value)), qos: mqttStateQoS, retain: true})
+	}
+	messages = append(messages, events...)
+	if len(messages) == 0 {
+		return
+	}
+
+	if err := m.publish(messages); err != nil {
+		m.report(fmt.Errorf("could not publish %d MQTT messages: %w", len(messages), err))
+		return
+	}
+	for _, topic := range announce {
+		m.announced[topic] = true
+	}
+}
+
+// publish sends all messages in order, connecting first if the connection is not open.
+// Each message has to be sent, and acknowledged for QoS 1, within the timeout.
+func (m *MQTT) publish(messages []mqttMessage) error {
+	if !m.client.IsConnectionOpen() {
+		if err := m.wait(m.client.Connect()); err != nil {
+			return fmt.Errorf("could not connect: %w", err)
+		}
+	}
+	for _, msg := range messages {
+		if err := m.wait(m.client.Publish(msg.topic, msg.qos, msg.retain, msg.payload)); err != nil {
+			return fmt.Errorf("could not publish to %s: %w", msg.topic, err)
+		}
+	}
+	return nil
+}
+
+// wait waits for a token to complete within the timeout.
+func (m *MQTT) wait(token mqtt.Token) error {
+	if !token.WaitTimeout(m.timeout) {
+		return errors.New("timeout")
+	}
+	return token.Error()
+}
+
+// Close disconnects from the broker.
+func (m *MQTT) Close() {
+	m.client.Disconnect(mqttDisconnectQuiesce)
+}
+
+func (m *MQTT) stateTopic(state *mqttState) string {
+	return strings.Join([]string{
+		m.opts.TopicPrefix,
+		mqttTopicLevel(state.username),
+		mqttTopicLevel(state.measurement),
+		mqttTopicLevel(state.field),
+	}, "/")
+}
+
+func (m *MQTT) eventMessage(measurement string, tags map[string]string, fields map[string]interface{}, t time.Time) (mqttMessage, error) {
+	payload, err := json.Marshal(jsonPoint{Measurement: measurement, Tags: tags, Fields: fields, Time: t})
+	if err != nil {
+		return mqttMessage{}, err
+	}
+	return mqttMessage{topic: m.opts.TopicPrefix + "/" + mqttEventsTopic, payload: payload, qos: mqttEventQoS}, nil
+}
+
+// rankValue is the rank field of a point.
+type rankValue struct {
+	tags  map[string]string
+	value interface{}
+	t     time.Time
+}
+
+// rankChange records the rank of a user and returns an event if it changed since the previous run.
+// Ranks of different seasons are not compared, as ranks are reset at the start of a season.
+func (m *MQTT) rankChange(username string, r rankValue) (mqttMessage, bool) {
+	rank, ok := r.value.(int64)
+	if !ok {
+		return mqttMessage{}, false
+	}
+	season := r.tags[m.opts.TagKeys.SeasonSlug]
+	key := strings.ToLower(username)
+	last, known := m.ranks[key]
+	m.ranks[key] = mqttRank{Season: season, Rank: rank}
+	previous := last.Rank
+	if !known || last.Season != season || previous == rank {
+		return mqttMessage{}, false
+	}
+
+	tags := map[string]string{m.opts.TagKeys.Username: username, "type": "rank_up"}
+	if rank < previous {
+		tags["type"] = "rank_down"
+	}
+	for _, k := range []string{m.opts.TagKeys.SeasonSlug, m.opts.TagKeys.SeasonName} {
+		if v, ok := r.tags[k]; ok {
+			tags[k] = v
+		}
+	}
+	fields := map[string]interface{}{"from": previous, "to": rank}
+	if m.opts.RankName != nil {
+		fields["from_name"] = m.opts.RankName(int(previous))
+		fields["to_name"] = m.opts.RankName(int(rank))
+	}
+	msg, err := m.eventMessage(rankChangeMeasurement, tags, fields, r.t)
+	if err != nil {
+		m.report(err)
+		return mqttMessage{}, false
+	}
+	return msg, true
+}
+
+// discoveryMessage creates the Home Assistant MQTT discovery payload for a state topic.
+func (m *MQTT) discoveryMessage(state *mqttState, topic string) (mqttMessage, error) {
+	user := mqttObjectID(state.username)
+	objectID := mqttObjectID(state.measurement + "_" + state.field)
+	config := map[string]interface{}{
+		"name":        strings.ReplaceAll(state.measurement+" "+state.field, "_", " "),
+		"state_topic": topic,
+		"unique_id":   mqttObjectID(m.opts.TopicPrefix) + "_" + user + "_" + objectID,
+		"device": map[string]interface{}{
+			"identifiers":  []string{mqttObjectID(m.opts.TopicPrefix) + "_" + user},
+			"name":         "R6 " + state.username,
+			"manufacturer": m.opts.ServiceName,
+		},
+	}
+	if _, isString := state.value.(string); !isString {
+		config["state_class"] = "measurement"
+	}
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return mqttMessage{}, err
+	}
+	return mqttMessage{
+		topic:   fmt.Sprintf("%s/sensor/%s/%s_%s/config", m.opts.DiscoveryPrefix, mqttObjectID(m.opts.TopicPrefix), user, objectID),
+		payload: payload,
+		qos:     mqttEventQoS,
+		retain:  true,
+	}, nil
+}
+
+// mqttTopicLevel makes s usable as single topic level by replacing separators and wildcards.
+func mqttTopicLevel(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '+', '#', ' ':
+			return '_'
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+// mqttObjectID makes s usable as Home Assistant object ID.
+func mqttObjectID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(s))
+}
@@ -0,0 +1,306 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// MQTT 3.1.1 control packet types used by testBroker, see https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html
+const (
+	testConnect    byte = 1 << 4
+	testConnAck    byte = 2 << 4
+	testPublish    byte = 3 << 4
+	testPubAck     byte = 4 << 4
+	testPingReq    byte = 12 << 4
+	testPingResp   byte = 13 << 4
+	testDisconnect byte = 14 << 4
+)
+
+// testMessage is a message received by testBroker.
+type testMessage struct {
+	topic   string
+	payload string
+	qos     byte
+	retain  bool
+}
+
+// testBroker is a minimal in-process MQTT broker, which checks the CONNECT packet,
+// acknowledges QoS 1 messages and records all PUBLISH packets.
+type testBroker struct {
+	t        *testing.T
+	listener net.Listener
+	messages chan testMessage
+	// connections is the number of accepted connections
+	connections atomic.Int32
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{t: t, listener: l, messages: make(chan testMessage, 100)}
+	go b.serve()
+	t.Cleanup(func() { _ = l.Close() })
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "mqtt://user:pass@" + b.listener.Addr().String()
+}
+
+func (b *testBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.connections.Add(1)
+		go func() {
+			defer conn.Close()
+			if err := b.handle(conn); err != nil {
+				b.t.Errorf("broker: %v", err)
+			}
+		}()
+	}
+}
+
+// handle serves a single connection until the client disconnects. Only protocol violations are returned as error.
+func (b *testBroker) handle(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	header, body, err := readTestPacket(r)
+	if err != nil {
+		return nil
+	}
+	if header != testConnect {
+		return fmt.Errorf("first packet has type %#x, want CONNECT", header)
+	}
+	protocol, body := readTestString(body)
+	if protocol != "MQTT" || len(body) < 4 || body[0] != 4 {
+		return fmt.Errorf("invalid protocol %q", protocol)
+	}
+	if flags := body[1]; flags != 0xc2 {
+		return fmt.Errorf("connect flags = %#x, want user name, password and clean session", flags)
+	}
+	clientID, body := readTestString(body[4:])
+	username, body := readTestString(body)
+	password, _ := readTestString(body)
+	if clientID != DefaultMQTTClientID || username != "user" || password != "pass" {
+		return fmt.Errorf("unexpected client %q with credentials %q/%q", clientID, username, password)
+	}
+	if _, err := conn.Write([]byte{testConnAck, 2, 0, 0}); err != nil {
+		return nil
+	}
+
+	for {
+		header, body, err := readTestPacket(r)
+		if err != nil {
+			return nil
+		}
+		switch header &^ 0x0f {
+		case testPublish:
+			qos := header >> 1 & 0x03
+			topic, rest := readTestString(body)
+			if qos > 0 {
+				if _, err := conn.Write([]byte{testPubAck, 2, rest[0], rest[1]}); err != nil {
+					return nil
+				}
+				rest = rest[2:]
+			}
+			b.messages <- testMessage{topic: topic, payload: string(rest), qos: qos, retain: header&0x01 != 0}
+		case testPingReq:
+			if _, err := conn.Write([]byte{testPingResp, 0}); err != nil {
+				return nil
+			}
+		case testDisconnect:
+			return nil
+		default:
+			return fmt.Errorf("unexpected packet type %#x", header)
+		}
+	}
+}
+
+func readTestPacket(r *bufio.Reader) (header byte, body []byte, err error) {
+	if header, err = r.ReadByte(); err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func readTestString(b []byte) (string, []byte) {
+	if len(b) < 2 {
+		return "", nil
+	}
+	n := int(b[0])<<8 | int(b[1])
+	if len(b) < 2+n {
+		return "", nil
+	}
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+// nextMessages returns the next n messages by topic and checks that no further messages were published.
+func (b *testBroker) nextMessages(t *testing.T, n int) map[string]testMessage {
+	t.Helper()
+	byTopic := make(map[string]testMessage, n)
+	for i := 0; i < n; i++ {
+		select {
+		case msg := <-b.messages:
+			if _, exists := byTopic[msg.topic]; exists && msg.topic != "r6prom/events" {
+				t.Errorf("topic %s published twice", msg.topic)
+			}
+			byTopic[msg.topic] = msg
+		case <-time.After(5 * time.Second):
+			t.Fatalf("broker received %d messages, want %d", i, n)
+		}
+	}
+	select {
+	case msg := <-b.messages:
+		t.Errorf("unexpected message %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+	return byTopic
+}
+
+func rankedPoint(season string, mmr int64, rank int64) *write.Point {
+	return influxdb2.NewPoint(
+		"ranked",
+		map[string]string{"username": "Alice", "season_slug": season},
+		map[string]interface{}{"mmr": mmr, "rank": rank},
+		time.Unix(1700000000, 0),
+	)
+}
+
+func newTestMQTT(t *testing.T, broker *testBroker, stateFile string) *MQTT {
+	t.Helper()
+	m, err := NewMQTT(MQTTOpts{
+		URL:             broker.url(),
+		Fields:          []string{"ranked.mmr"},
+		Events:          []string{"season_change"},
+		RankField:       "ranked.rank",
+		RankName:        func(ordinal int) string { return fmt.Sprintf("rank %d", ordinal) },
+		DiscoveryPrefix: "homeassistant",
+		ServiceName:     "r6prom",
+		RankStateFile:   stateFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	m.RegisterSeason("Y8S4")
+	return m
+}
+
+func checkNoSinkErrors(t *testing.T, s Sink) {
+	t.Helper()
+	select {
+	case err := <-s.Errors():
+		t.Fatal(err)
+	default:
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	broker := newTestBroker(t)
+	stateFile := filepath.Join(t.TempDir(), "ranks.json")
+	m := newTestMQTT(t, broker, stateFile)
+
+	// the current season is followed by a past season of the ranked history
+	m.WritePoint(rankedPoint("Y8S4", 3000, 20))
+	m.WritePoint(rankedPoint("Y8S3", 2500, 10))
+	m.WritePoint(influxdb2.NewPoint("season_change", map[string]string{"season_slug": "Y8S4"}, map[string]interface{}{"changed": true}, time.Unix(1700000000, 0)))
+	m.Flush()
+	checkNoSinkErrors(t, m)
+
+	messages := broker.nextMessages(t, 3)
+	if msg := messages["r6prom/alice/ranked/mmr"]; msg.payload != "3000" || !msg.retain || msg.qos != 0 {
+		t.Errorf("state message = %+v, want retained current season MMR with QoS 0", msg)
+	}
+	discovery, ok := messages["homeassistant/sensor/r6prom/alice_ranked_mmr/config"]
+	if !ok || !discovery.retain || discovery.qos != 1 {
+		t.Fatalf("discovery message = %+v, want retained message with QoS 1", discovery)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(discovery.payload), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config["state_topic"] != "r6prom/alice/ranked/mmr" || config["unique_id"] != "r6prom_alice_ranked_mmr" || config["state_class"] != "measurement" {
+		t.Errorf("unexpected discovery config %v", config)
+	}
+	if msg := messages["r6prom/events"]; msg.retain || msg.payload == "" || msg.qos != 1 {
+		t.Errorf("event message = %+v, want unretained season change with QoS 1", msg)
+	}
+
+	// rank changes are only detected within the current season
+	m.WritePoint(rankedPoint("Y8S4", 3100, 21))
+	m.WritePoint(rankedPoint("Y8S3", 2500, 5))
+	m.Flush()
+	checkNoSinkErrors(t, m)
+
+	messages = broker.nextMessages(t, 2)
+	if _, ok := messages["homeassistant/sensor/r6prom/alice_ranked_mmr/config"]; ok {
+		t.Error("discovery message published again")
+	}
+	checkRankEvent(t, messages["r6prom/events"], "Y8S4", "rank_up", 20, 21)
+	if n := broker.connections.Load(); n != 1 {
+		t.Errorf("sink opened %d connections, want 1 for all flushes", n)
+	}
+
+	// the rank state is persisted across restarts
+	m.Close()
+	m = newTestMQTT(t, broker, stateFile)
+	m.WritePoint(rankedPoint("Y8S4", 3000, 19))
+	m.Flush()
+	checkNoSinkErrors(t, m)
+	checkRankEvent(t, broker.nextMessages(t, 3)["r6prom/events"], "Y8S4", "rank_down", 21, 19)
+
+	// ranks are reset by a new season, which is no rank change
+	m.RegisterSeason("Y9S1")
+	m.WritePoint(rankedPoint("Y9S1", 2500, 0))
+	m.Flush()
+	checkNoSinkErrors(t, m)
+	if _, ok := broker.nextMessages(t, 1)["r6prom/events"]; ok {
+		t.Error("rank change published for the season reset")
+	}
+	m.WritePoint(rankedPoint("Y9S1", 2600, 12))
+	m.Flush()
+	checkNoSinkErrors(t, m)
+	checkRankEvent(t, broker.nextMessages(t, 2)["r6prom/events"], "Y9S1", "rank_up", 0, 12)
+}
+
+func checkRankEvent(t *testing.T, msg testMessage, season string, wantType string, from int64, to int64) {
+	t.Helper()
+	var event jsonPoint
+	if err := json.Unmarshal([]byte(msg.payload), &event); err != nil {
+		t.Fatalf("invalid event %q: %v", msg.payload, err)
+	}
+	if event.Measurement != rankChangeMeasurement || event.Tags["type"] != wantType || event.Tags["season_slug"] != season {
+		t.Errorf("event = %+v, want %s in season %s", event, wantType, season)
+	}
+	if event.Fields["from"] != float64(from) || event.Fields["to"] != float64(to) || event.Fields["to_name"] != fmt.Sprintf("rank %d", to) {
+		t.Errorf("event fields = %v, want change from %d to %d", event.Fields, from, to)
+	}
+}
@@ -0,0 +1,265 @@
+package sink
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// otlpMetricsPath is appended to OTLP endpoints without path, like the OpenTelemetry SDKs do.
+const otlpMetricsPath = "/v1/metrics"
+
+// profileIDAttribute is the resource attribute holding the profile ID of the user.
+const profileIDAttribute = "profile_id"
+
+// OTLPOpts configures an OTLP sink.
+type OTLPOpts struct {
+	// Endpoint is the OTLP/HTTP endpoint, "/v1/metrics" is appended if it has no path.
+	Endpoint string
+	// Headers are sent with every request, e.g. for authentication.
+	Headers map[string]string
+	// ServiceName and ServiceVersion are set as resource and scope attributes.
+	ServiceName    string
+	ServiceVersion string
+	// BatchSize is the maximum number of points per request, DefaultBatchSize if 0.
+	BatchSize int
+	// Timeout limits each request, DefaultHTTPTimeout if 0.
+	Timeout time.Duration
+	// TagKeys are the written tag keys, DefaultTagKeys if unset.
+	TagKeys TagKeys
+}
+
+// OTLP exports points as OpenTelemetry gauges via OTLP/HTTP with JSON encoding.
+// Every numeric field becomes a gauge named "<measurement>.<field>" with the point tags as attributes,
+// except for username and profile ID which are resource attributes. String fields are skipped.
+type OTLP struct {
+	errorReporter
+	endpoint       *httpEndpoint
+	serviceName    string
+	serviceVersion string
+	batchSize      int
+	usernameTag    string
+	// resourceTags are moved from the data point attributes to the resource attributes
+	resourceTags []string
+
+	mu         sync.Mutex
+	pending    []*write.Point
+	profileIDs map[string]string
+}
+
+// NewOTLP creates an OTLP sink.
+func NewOTLP(opts OTLPOpts) (*OTLP, error) {
+	u, err := parseHTTPURL(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpMetricsPath
+	}
+	endpoint := newHTTPEndpoint(u.String(), opts.Timeout)
+	for k, v := range opts.Headers {
+		endpoint.headers[k] = v
+	}
+	return &OTLP{
+		errorReporter:  newErrorReporter(),
+		endpoint:       endpoint,
+		serviceName:    opts.ServiceName,
+		serviceVersion: opts.ServiceVersion,
+		batchSize:      opts.BatchSize,
+		usernameTag:    opts.TagKeys.orDefault().Username,
+		resourceTags:   []string{opts.TagKeys.orDefault().Username, profileIDAttribute},
+		profileIDs:     map[string]string{},
+	}, nil
+}
+
+func (o *OTLP) RegisterProfile(username string, profileID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.profileIDs[strings.ToLower(username)] = profileID
+}
+
+func (o *OTLP) WritePoint(p *write.Point) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = append(o.pending, p)
+}
+
+// Flush sends all pending points in batches of at most BatchSize points.
+func (o *OTLP) Flush() {
+	o.mu.Lock()
+	pending := o.pending
+	o.pending = nil
+	o.mu.Unlock()
+
+	batches(pending, o.batchSize, o.errorReporter, o.send)
+}
+
+// OTLP JSON encoding of ExportMetricsServiceRequest, see opentelemetry-proto.
+// 64 bit integers are encoded as strings as required by the protobuf JSON mapping.
+
+type otlpRequest struct {
+	ResourceMetrics []*otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource        `json:"resource"`
+	ScopeMetrics []*otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope     `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsInt        *string         `json:"asInt,omitempty"`
+	AsDouble     *float64        `json:"asDouble,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func stringAttribute(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+func (o *OTLP) send(points []*write.Point) error {
+	req := o.toRequest(points)
+	if len(req.ResourceMetrics) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return o.endpoint.post(body, map[string]string{
+		"Content-Type": "application/json",
+	})
+}
+
+// toRequest groups points by resource and metric name.
+func (o *OTLP) toRequest(points []*write.Point) otlpRequest {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var req otlpRequest
+	resources := map[string]*otlpScopeMetrics{}
+	byName := map[string]*otlpMetric{}
+
+	for _, p := range points {
+		tags := metrics.PointTags(p)
+		if username := tags[o.usernameTag]; username != "" && tags[profileIDAttribute] == "" {
+			if id, ok := o.profileIDs[strings.ToLower(username)]; ok {
+				tags[profileIDAttribute] = id
+			}
+		}
+
+		resourceAttrs := []otlpAttribute{stringAttribute("service.name", o.serviceName)}
+		for _, key := range o.resourceTags {
+			if v := tags[key]; v != "" {
+				resourceAttrs = append(resourceAttrs, stringAttribute(key, v))
+			}
+			delete(tags, key)
+		}
+		resourceKey := ""
+		for _, a := range resourceAttrs {
+			resourceKey += a.Key + "=" + a.Value.StringValue + "\x00"
+		}
+		scope, ok := resources[resourceKey]
+		if !ok {
+			scope = &otlpScopeMetrics{Scope: otlpScope{Name: o.serviceName, Version: o.serviceVersion}}
+			resources[resourceKey] = scope
+			req.ResourceMetrics = append(req.ResourceMetrics, &otlpResourceMetrics{
+				Resource:     otlpResource{Attributes: resourceAttrs},
+				ScopeMetrics: []*otlpScopeMetrics{scope},
+			})
+		}
+
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]otlpAttribute, 0, len(keys))
+		for _, k := range keys {
+			if tags[k] != "" {
+				attrs = append(attrs, stringAttribute(k, tags[k]))
+			}
+		}
+		timestamp := strconv.FormatInt(p.Time().UnixNano(), 10)
+
+		for _, field := range p.FieldList() {
+			dp, ok := otlpDataPointValue(field.Value)
+			if !ok {
+				continue
+			}
+			dp.Attributes = attrs
+			dp.TimeUnixNano = timestamp
+
+			name := p.Name() + "." + field.Key
+			metric, ok := byName[resourceKey+name]
+			if !ok {
+				metric = &otlpMetric{Name: name}
+				byName[resourceKey+name] = metric
+				scope.Metrics = append(scope.Metrics, metric)
+			}
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, dp)
+		}
+	}
+	return req
+}
+
+// otlpDataPointValue sets the value of a data point, ok is false for non-numeric values.
+func otlpDataPointValue(v interface{}) (dp otlpDataPoint, ok bool) {
+	var i int64
+	switch x := v.(type) {
+	case float64:
+		dp.AsDouble = &x
+		return dp, true
+	case int64:
+		i = x
+	case uint64:
+		i = int64(x)
+	case bool:
+		if x {
+			i = 1
+		}
+	default:
+		return dp, false
+	}
+	s := strconv.FormatInt(i, 10)
+	dp.AsInt = &s
+	return dp, true
+}
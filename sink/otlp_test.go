@@ -0,0 +1,188 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// otlpTestRequest decodes the parts of an OTLP JSON request checked by the tests, independently of the sink's types.
+type otlpTestRequest struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []otlpTestAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Scope struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"scope"`
+			Metrics []struct {
+				Name  string `json:"name"`
+				Gauge struct {
+					DataPoints []struct {
+						Attributes   []otlpTestAttribute `json:"attributes"`
+						TimeUnixNano string              `json:"timeUnixNano"`
+						AsInt        *string             `json:"asInt"`
+						AsDouble     *float64            `json:"asDouble"`
+					} `json:"dataPoints"`
+				} `json:"gauge"`
+			} `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+type otlpTestAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func attributeMap(attrs []otlpTestAttribute) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[a.Key] = a.Value.StringValue
+	}
+	return m
+}
+
+func TestOTLPExport(t *testing.T) {
+	requests := make(chan otlpTestRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpMetricsPath {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %s", ct)
+		}
+		if auth := r.Header.Get("X-Auth"); auth != "secret" {
+			t.Errorf("missing configured header, got %q", auth)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req otlpTestRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid JSON: %v", err)
+		}
+		requests <- req
+	}))
+	defer server.Close()
+
+	o, err := NewOTLP(OTLPOpts{
+		Endpoint:       server.URL,
+		Headers:        map[string]string{"X-Auth": "secret"},
+		ServiceName:    "r6prom",
+		ServiceVersion: "1.0.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.RegisterProfile("Alice", "id-alice")
+
+	ts := time.Unix(1700000000, 0)
+	o.WritePoint(influxdb2.NewPoint("maps", map[string]string{"username": "alice", "map": "Oregon"}, map[string]interface{}{"kills": 3, "kd_ratio": 1.5, "won": true, "name": "skipped"}, ts))
+	o.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"username": "bob", "season_slug": "Y8S4"}, map[string]interface{}{"mmr": 3000}, ts))
+	o.Flush()
+
+	var req otlpTestRequest
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+	}
+	select {
+	case err := <-o.Errors():
+		t.Fatal(err)
+	default:
+	}
+
+	if len(req.ResourceMetrics) != 2 {
+		t.Fatalf("got %d resources, want 2", len(req.ResourceMetrics))
+	}
+	type gauge struct {
+		resource   map[string]string
+		attributes map[string]string
+		time       string
+		asInt      string
+		asDouble   float64
+	}
+	got := map[string]gauge{}
+	for _, rm := range req.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			if sm.Scope.Name != "r6prom" || sm.Scope.Version != "1.0.0" {
+				t.Errorf("unexpected scope %+v", sm.Scope)
+			}
+			for _, m := range sm.Metrics {
+				if len(m.Gauge.DataPoints) != 1 {
+					t.Fatalf("metric %s has %d data points, want 1", m.Name, len(m.Gauge.DataPoints))
+				}
+				dp := m.Gauge.DataPoints[0]
+				g := gauge{resource: attributeMap(rm.Resource.Attributes), attributes: attributeMap(dp.Attributes), time: dp.TimeUnixNano}
+				if dp.AsInt != nil {
+					g.asInt = *dp.AsInt
+				}
+				if dp.AsDouble != nil {
+					g.asDouble = *dp.AsDouble
+				}
+				got[m.Name] = g
+			}
+		}
+	}
+
+	aliceResource := map[string]string{"service.name": "r6prom", "username": "alice", "profile_id": "id-alice"}
+	bobResource := map[string]string{"service.name": "r6prom", "username": "bob"}
+	timestamp := "1700000000000000000"
+	want := map[string]gauge{
+		"maps.kills":    {aliceResource, map[string]string{"map": "Oregon"}, timestamp, "3", 0},
+		"maps.kd_ratio": {aliceResource, map[string]string{"map": "Oregon"}, timestamp, "", 1.5},
+		"maps.won":      {aliceResource, map[string]string{"map": "Oregon"}, timestamp, "1", 0},
+		"ranked.mmr":    {bobResource, map[string]string{"season_slug": "Y8S4"}, timestamp, "3000", 0},
+	}
+	names := make([]string, 0, len(got))
+	for name := range got {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(got) != len(want) {
+		t.Fatalf("got metrics %v, want %d metrics", names, len(want))
+	}
+	for name, w := range want {
+		if g, ok := got[name]; !ok || !reflect.DeepEqual(g, w) {
+			t.Errorf("%s = %+v, want %+v", name, g, w)
+		}
+	}
+}
+
+func TestOTLPRenamedUsernameTag(t *testing.T) {
+	o, err := NewOTLP(OTLPOpts{Endpoint: "http://localhost:4318", ServiceName: "r6prom", TagKeys: TagKeys{Username: "player"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.RegisterProfile("alice", "id-alice")
+	req := o.toRequest([]*write.Point{
+		influxdb2.NewPoint("maps", map[string]string{"player": "alice", "map": "Oregon"}, map[string]interface{}{"kills": 3}, time.Unix(1700000000, 0)),
+	})
+	if len(req.ResourceMetrics) != 1 {
+		t.Fatalf("got %d resources, want 1", len(req.ResourceMetrics))
+	}
+	resource := map[string]string{}
+	for _, a := range req.ResourceMetrics[0].Resource.Attributes {
+		resource[a.Key] = a.Value.StringValue
+	}
+	if want := map[string]string{"service.name": "r6prom", "player": "alice", "profile_id": "id-alice"}; !reflect.DeepEqual(resource, want) {
+		t.Errorf("resource attributes = %v, want %v", resource, want)
+	}
+	for _, a := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Gauge.DataPoints[0].Attributes {
+		if a.Key == "player" {
+			t.Error("renamed username tag kept as data point attribute")
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package sink
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// DefaultRemoteWriteNamespace is prepended to all metric names.
+const DefaultRemoteWriteNamespace = "r6"
+
+// RemoteWriteOpts configures a RemoteWrite sink.
+type RemoteWriteOpts struct {
+	// URL is the remote-write endpoint, e.g. "http://mimir:9009/api/v1/push".
+	URL string
+	// Namespace is prepended to metric names, DefaultRemoteWriteNamespace if empty.
+	Namespace string
+	// Username and Password enable basic auth if Username is set.
+	Username string
+	Password string
+	// BearerToken is sent as Authorization header if set.
+	BearerToken string
+	// BatchSize is the maximum number of points per request, DefaultBatchSize if 0.
+	BatchSize int
+	// Timeout limits each request, DefaultHTTPTimeout if 0.
+	Timeout time.Duration
+}
+
+// RemoteWrite pushes points using the Prometheus remote-write protocol.
+// Every numeric field becomes a series named "<namespace>_<measurement>_<field>" with the point tags as labels.
+// Samples keep the original point time, string fields are skipped.
+type RemoteWrite struct {
+	errorReporter
+	endpoint  *httpEndpoint
+	namespace string
+	batchSize int
+
+	mu      sync.Mutex
+	pending []*write.Point
+}
+
+// NewRemoteWrite creates a remote-write sink.
+func NewRemoteWrite(opts RemoteWriteOpts) (*RemoteWrite, error) {
+	if _, err := parseHTTPURL(opts.URL); err != nil {
+		return nil, err
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultRemoteWriteNamespace
+	}
+	endpoint := newHTTPEndpoint(opts.URL, opts.Timeout)
+	endpoint.username, endpoint.password = opts.Username, opts.Password
+	endpoint.bearerToken = opts.BearerToken
+	return &RemoteWrite{
+		errorReporter: newErrorReporter(),
+		endpoint:      endpoint,
+		namespace:     sanitizeMetricName(opts.Namespace),
+		batchSize:     opts.BatchSize,
+	}, nil
+}
+
+func (r *RemoteWrite) WritePoint(p *write.Point) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, p)
+}
+
+// Flush sends all pending points in batches of at most BatchSize points.
+func (r *RemoteWrite) Flush() {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	batches(pending, r.batchSize, r.errorReporter, r.send)
+}
+
+type promLabel struct {
+	name  string
+	value string
+}
+
+type promSample struct {
+	value     float64
+	timestamp int64
+}
+
+type promSeries struct {
+	labels  []promLabel
+	samples []promSample
+}
+
+func (r *RemoteWrite) send(points []*write.Point) error {
+	series := r.toSeries(points)
+	if len(series) == 0 {
+		return nil
+	}
+	return r.endpoint.post(snappy.Encode(nil, encodeWriteRequest(series)), map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	})
+}
+
+// toSeries converts points into series, merging samples of identical label sets.
+func (r *RemoteWrite) toSeries(points []*write.Point) []*promSeries {
+	byKey := map[string]*promSeries{}
+	var keys []string
+	for _, p := range points {
+		for _, field := range p.FieldList() {
+			value, ok := sampleValue(field.Value)
+			if !ok {
+				continue
+			}
+			labels := make([]promLabel, 0, len(p.TagList())+1)
+			labels = append(labels, promLabel{
+				name:  "__name__",
+				value: PrometheusMetricName(r.namespace, p.Name(), field.Key),
+			})
+			for _, tag := range p.TagList() {
+				if tag.Value == "" {
+					continue
+				}
+				labels = append(labels, promLabel{name: PrometheusLabelName(tag.Key), value: tag.Value})
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+			var kb strings.Builder
+			for _, l := range labels {
+				kb.WriteString(l.name)
+				kb.WriteByte(0)
+				kb.WriteString(l.value)
+				kb.WriteByte(0)
+			}
+			key := kb.String()
+			s, exists := byKey[key]
+			if !exists {
+				s = &promSeries{labels: labels}
+				byKey[key] = s
+				keys = append(keys, key)
+			}
+			s.samples = append(s.samples, promSample{value: value, timestamp: p.Time().UnixMilli()})
+		}
+	}
+
+	series := make([]*promSeries, len(keys))
+	for i, key := range keys {
+		s := byKey[key]
+		sort.SliceStable(s.samples, func(i, j int) bool { return s.samples[i].timestamp < s.samples[j].timestamp })
+		series[i] = s
+	}
+	return series
+}
+
+// sampleValue converts a field value to a sample value, ok is false for non-numeric values.
+func sampleValue(v interface{}) (value float64, ok bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// PrometheusMetricName returns the name of the metric a field of a measurement is written as.
+func PrometheusMetricName(namespace string, measurement string, field string) string {
+	return sanitizeMetricName(namespace) + "_" + sanitizeMetricName(measurement) + "_" + sanitizeMetricName(field)
+}
+
+// PrometheusLabelName returns the name of the label a tag is written as.
+func PrometheusLabelName(tag string) string {
+	return sanitizeLabelName(tag)
+}
+
+// sanitizeMetricName replaces all characters not allowed in Prometheus metric names with underscores.
+func sanitizeMetricName(s string) string {
+	return sanitizeName(s, true)
+}
+
+// sanitizeLabelName replaces all characters not allowed in Prometheus label names with underscores.
+func sanitizeLabelName(s string) string {
+	return sanitizeName(s, false)
+}
+
+func sanitizeName(s string, allowColon bool) string {
+	b := []byte(s)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9') || (allowColon && c == ':')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// encodeWriteRequest encodes series as prometheus.WriteRequest protobuf message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []*promSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var label []byte
+			label = appendProtoString(label, 1, l.name)
+			label = appendProtoString(label, 2, l.value)
+			ts = appendProtoBytes(ts, 1, label)
+		}
+		for _, sample := range s.samples {
+			var msg []byte
+			msg = binary.AppendUvarint(msg, 1<<3|protoWireFixed64)
+			msg = binary.LittleEndian.AppendUint64(msg, math.Float64bits(sample.value))
+			msg = binary.AppendUvarint(msg, 2<<3|protoWireVarint)
+			msg = binary.AppendUvarint(msg, uint64(sample.timestamp))
+			ts = appendProtoBytes(ts, 2, msg)
+		}
+		req = appendProtoBytes(req, 1, ts)
+	}
+	return req
+}
+
+// protobuf wire types
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+)
+
+func appendProtoBytes(b []byte, fieldNum int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(fieldNum)<<3|protoWireBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendProtoString(b []byte, fieldNum int, value string) []byte {
+	b = binary.AppendUvarint(b, uint64(fieldNum)<<3|protoWireBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
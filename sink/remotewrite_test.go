@@ -0,0 +1,151 @@
+package sink
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+func TestEncodeWriteRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []*promSeries
+		want   []byte
+	}{
+		{
+			name: "empty",
+			want: nil,
+		},
+		{
+			name: "single sample",
+			series: []*promSeries{{
+				labels:  []promLabel{{name: "__name__", value: "a"}},
+				samples: []promSample{{value: 1.5, timestamp: 1000}},
+			}},
+			want: concatBytes(
+				[]byte{0x0a, 29}, // timeseries
+				[]byte{0x0a, 13}, // label
+				[]byte{0x0a, 8}, []byte("__name__"),
+				[]byte{0x12, 1}, []byte("a"),
+				[]byte{0x12, 12}, // sample
+				[]byte{0x09, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f},
+				[]byte{0x10, 0xe8, 0x07},
+			),
+		},
+		{
+			name: "multiple series and samples",
+			series: []*promSeries{
+				{
+					labels:  []promLabel{{name: "a", value: "b"}},
+					samples: []promSample{{value: 0, timestamp: 1}, {value: 2, timestamp: 2}},
+				},
+				{
+					labels:  []promLabel{{name: "c", value: ""}},
+					samples: []promSample{{value: -1, timestamp: 3}},
+				},
+			},
+			want: concatBytes(
+				[]byte{0x0a, 34},
+				[]byte{0x0a, 6, 0x0a, 1, 'a', 0x12, 1, 'b'},
+				[]byte{0x12, 11, 0x09, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 1},
+				[]byte{0x12, 11, 0x09, 0, 0, 0, 0, 0, 0, 0, 0x40, 0x10, 2},
+				[]byte{0x0a, 20},
+				[]byte{0x0a, 5, 0x0a, 1, 'c', 0x12, 0},
+				[]byte{0x12, 11, 0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0xbf, 0x10, 3},
+			),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeWriteRequest(tt.series); !bytes.Equal(got, tt.want) {
+				t.Errorf("encodeWriteRequest() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	var result []byte
+	for _, p := range parts {
+		result = append(result, p...)
+	}
+	return result
+}
+
+func TestRemoteWriteToSeries(t *testing.T) {
+	r := &RemoteWrite{namespace: "r6"}
+	ts := time.UnixMilli(1700000000000)
+	points := []*write.Point{
+		influxdb2.NewPoint("maps", map[string]string{"map": "Oregon", "username": "a", "empty": ""}, map[string]interface{}{"kills": 3, "name": "x"}, ts.Add(time.Second)),
+		influxdb2.NewPoint("maps", map[string]string{"map": "Oregon", "username": "a"}, map[string]interface{}{"kills": 2}, ts),
+		influxdb2.NewPoint("ranked", map[string]string{"season-slug": "Y8S4"}, map[string]interface{}{"won": true}, ts),
+	}
+
+	got := r.toSeries(points)
+	want := []*promSeries{
+		{
+			labels:  []promLabel{{"__name__", "r6_maps_kills"}, {"map", "Oregon"}, {"username", "a"}},
+			samples: []promSample{{value: 2, timestamp: ts.UnixMilli()}, {value: 3, timestamp: ts.UnixMilli() + 1000}},
+		},
+		{
+			labels:  []promLabel{{"__name__", "r6_ranked_won"}, {"season_slug", "Y8S4"}},
+			samples: []promSample{{value: 1, timestamp: ts.UnixMilli()}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("toSeries() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPrometheusNames(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantMetric string
+		wantLabel  string
+	}{
+		{"kills", "kills", "kills"},
+		{"season-slug", "season_slug", "season_slug"},
+		{"a:b", "a:b", "a_b"},
+		{"1st", "_st", "_st"},
+		{"x1", "x1", "x1"},
+		{"Jäger", "J__ger", "J__ger"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeMetricName(tt.in); got != tt.wantMetric {
+			t.Errorf("sanitizeMetricName(%q) = %q, want %q", tt.in, got, tt.wantMetric)
+		}
+		if got := PrometheusLabelName(tt.in); got != tt.wantLabel {
+			t.Errorf("PrometheusLabelName(%q) = %q, want %q", tt.in, got, tt.wantLabel)
+		}
+	}
+	if got := PrometheusMetricName("r6", "maps", "kd-ratio"); got != "r6_maps_kd_ratio" {
+		t.Errorf("PrometheusMetricName() = %q", got)
+	}
+}
+
+func TestRemoteWriteCompression(t *testing.T) {
+	series := make([]*promSeries, 50)
+	for i := range series {
+		series[i] = &promSeries{
+			labels:  []promLabel{{"__name__", "r6_maps_kills"}, {"map", "Oregon"}, {"username", "a"}},
+			samples: []promSample{{value: float64(i), timestamp: 1700000000000}},
+		}
+	}
+	raw := encodeWriteRequest(series)
+	compressed := snappy.Encode(nil, raw)
+	if len(compressed) >= len(raw) {
+		t.Errorf("compressed size %d is not smaller than raw size %d", len(compressed), len(raw))
+	}
+	decoded, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, raw) {
+		t.Error("decoded payload differs from encoded payload")
+	}
+}
@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RotateOpts controls when files are rotated.
+type RotateOpts struct {
+	// MaxSize rotates files once they exceed this many bytes, 0 disables size based rotation.
+	MaxSize int64
+	// Daily rotates files when the local date changes.
+	Daily bool
+	// Gzip compresses files after they are rotated or closed.
+	Gzip bool
+}
+
+const dayLayout = "2006-01-02"
+
+// rotatingFile is a buffered file named "<prefix>-<date>.<index>.<ext>" which is replaced by a new file
+// according to RotateOpts. Existing files are never appended to or overwritten.
+type rotatingFile struct {
+	dir    string
+	prefix string
+	ext    string
+	opts   RotateOpts
+
+	f    *os.File
+	w    *bufio.Writer
+	size int64
+	day  string
+}
+
+func newRotatingFile(dir string, prefix string, ext string, opts RotateOpts) *rotatingFile {
+	return &rotatingFile{dir: dir, prefix: prefix, ext: ext, opts: opts}
+}
+
+// needsRotation reports whether the current file needs to be replaced before writing at t.
+func (r *rotatingFile) needsRotation(t time.Time) bool {
+	if r.f == nil {
+		return true
+	}
+	if r.opts.Daily && t.Format(dayLayout) != r.day {
+		return true
+	}
+	return r.opts.MaxSize > 0 && r.size >= r.opts.MaxSize
+}
+
+// open closes the current file and opens the next free file for the date of t.
+func (r *rotatingFile) open(t time.Time) error {
+	if err := r.close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+
+	day := t.Format(dayLayout)
+	for index := 0; ; index++ {
+		path := filepath.Join(r.dir, fmt.Sprintf("%s-%s.%d.%s", r.prefix, day, index, r.ext))
+		if exists(path) || exists(path+".gz") {
+			continue
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		r.f = f
+		r.w = bufio.NewWriter(f)
+		r.size = 0
+		r.day = day
+		return nil
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	n, err := r.w.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// flush writes buffered data to disk.
+func (r *rotatingFile) flush() error {
+	if r.w == nil {
+		return nil
+	}
+	return r.w.Flush()
+}
+
+// close flushes and closes the current file, compressing it if configured.
+func (r *rotatingFile) close() error {
+	if r.f == nil {
+		return nil
+	}
+	path := r.f.Name()
+	err := r.w.Flush()
+	if closeErr := r.f.Close(); err == nil {
+		err = closeErr
+	}
+	r.f, r.w = nil, nil
+	if err != nil {
+		return err
+	}
+	if r.opts.Gzip {
+		return gzipFile(path)
+	}
+	return nil
+}
+
+// gzipFile compresses path to path.gz and removes the original.
+func gzipFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Remove(path)
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	if _, err = io.Copy(zw, src); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,140 @@
+package sink
+
+import (
+	"sync"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// Sink receives all points written by the store.
+// The InfluxDB WriteAPI satisfies this interface.
+type Sink interface {
+	// WritePoint queues a point for writing.
+	WritePoint(p *write.Point)
+	// Flush writes all queued points.
+	Flush()
+	// Errors returns a channel for errors which occur while writing.
+	Errors() <-chan error
+}
+
+// ProfileRegistry is implemented by sinks which need the profile ID of users.
+type ProfileRegistry interface {
+	// RegisterProfile associates a username as written in the username tag with a profile ID.
+	RegisterProfile(username string, profileID string)
+}
+
+// SeasonRegistry is implemented by sinks which need to know the current season.
+type SeasonRegistry interface {
+	// RegisterSeason sets the slug of the current season as written in the season slug tag.
+	RegisterSeason(slug string)
+}
+
+// TagKeys are the keys of the tags interpreted by sinks, as written after renaming.
+type TagKeys struct {
+	Username   string
+	SeasonSlug string
+	SeasonName string
+}
+
+// DefaultTagKeys are the tag keys if no tags are renamed.
+var DefaultTagKeys = TagKeys{Username: "username", SeasonSlug: "season_slug", SeasonName: "season_name"}
+
+// orDefault returns DefaultTagKeys for unset keys.
+func (k TagKeys) orDefault() TagKeys {
+	if k.Username == "" {
+		k.Username = DefaultTagKeys.Username
+	}
+	if k.SeasonSlug == "" {
+		k.SeasonSlug = DefaultTagKeys.SeasonSlug
+	}
+	if k.SeasonName == "" {
+		k.SeasonName = DefaultTagKeys.SeasonName
+	}
+	return k
+}
+
+// errorBufferSize is the number of errors buffered by sinks before new errors are dropped.
+const errorBufferSize = 100
+
+// errorReporter implements the Errors method for sinks, dropping errors if nobody reads them.
+type errorReporter struct {
+	errs chan error
+}
+
+func newErrorReporter() errorReporter {
+	return errorReporter{errs: make(chan error, errorBufferSize)}
+}
+
+func (r errorReporter) Errors() <-chan error {
+	return r.errs
+}
+
+func (r errorReporter) report(err error) {
+	select {
+	case r.errs <- err:
+	default:
+	}
+}
+
+// Multi writes all points to multiple sinks and merges their errors.
+type Multi struct {
+	sinks []Sink
+	errs  chan error
+}
+
+// NewMulti creates a sink writing to all given sinks.
+func NewMulti(sinks ...Sink) *Multi {
+	m := &Multi{
+		sinks: sinks,
+		errs:  make(chan error, errorBufferSize),
+	}
+	var wg sync.WaitGroup
+	for _, s := range sinks {
+		wg.Add(1)
+		go func(errs <-chan error) {
+			defer wg.Done()
+			for err := range errs {
+				m.errs <- err
+			}
+		}(s.Errors())
+	}
+	go func() {
+		wg.Wait()
+		close(m.errs)
+	}()
+	return m
+}
+
+func (m *Multi) WritePoint(p *write.Point) {
+	for _, s := range m.sinks {
+		s.WritePoint(p)
+	}
+}
+
+func (m *Multi) Flush() {
+	for _, s := range m.sinks {
+		s.Flush()
+	}
+}
+
+func (m *Multi) Errors() <-chan error {
+	return m.errs
+}
+
+// RegisterProfile forwards the profile to all sinks implementing ProfileRegistry.
+func (m *Multi) RegisterProfile(username string, profileID string) {
+	for _, s := range m.sinks {
+		if r, ok := s.(ProfileRegistry); ok {
+			r.RegisterProfile(username, profileID)
+		}
+	}
+}
+
+// RegisterSeason forwards the season to all sinks implementing SeasonRegistry.
+func (m *Multi) RegisterSeason(slug string) {
+	for _, s := range m.sinks {
+		if r, ok := s.(SeasonRegistry); ok {
+			r.RegisterSeason(slug)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package sink
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+
+	// registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// timeColumn holds the point time as unix timestamp in seconds.
+	timeColumn = "time"
+	// timeKeyColumn holds tags and fields named like the time column.
+	timeKeyColumn = "time_key"
+)
+
+// SQLite writes points into a SQLite database with one table per measurement.
+// Tags and fields are stored as columns, new columns are added automatically when they first appear.
+// Tags and fields named "time" are stored in the "time_key" column, as the time column holds the point time.
+type SQLite struct {
+	errorReporter
+	db *sql.DB
+	// indexedColumns get an index as soon as they exist in a table
+	indexedColumns []string
+
+	mu      sync.Mutex
+	pending []*write.Point
+	// columns caches the known columns per table
+	columns map[string]map[string]bool
+}
+
+// NewSQLite opens or creates the SQLite database at path.
+// The username and season slug columns are indexed, using the given tag keys.
+func NewSQLite(path string, tagKeys TagKeys) (*SQLite, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	tagKeys = tagKeys.orDefault()
+	return &SQLite{
+		errorReporter:  newErrorReporter(),
+		db:             db,
+		indexedColumns: []string{timeColumn, tagKeys.Username, tagKeys.SeasonSlug},
+		columns:        map[string]map[string]bool{},
+	}, nil
+}
+
+func (s *SQLite) WritePoint(p *write.Point) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, p)
+}
+
+// Flush writes all pending points in a single transaction.
+func (s *SQLite) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return
+	}
+	if err := s.writePoints(s.pending); err != nil {
+		s.report(fmt.Errorf("could not write %d points to SQLite: %w", len(s.pending), err))
+	}
+	s.pending = nil
+}
+
+func (s *SQLite) writePoints(points []*write.Point) (err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			// schema changes were rolled back as well
+			s.columns = map[string]map[string]bool{}
+		}
+	}()
+
+	for _, p := range points {
+		if err = s.ensureColumns(tx, p); err != nil {
+			return
+		}
+		columns := []string{quoteIdent(timeColumn)}
+		values := []interface{}{p.Time().Unix()}
+		seen := map[string]bool{timeColumn: true}
+		for _, tag := range p.TagList() {
+			column := columnName(tag.Key)
+			columns = append(columns, quoteIdent(column))
+			values = append(values, tag.Value)
+			seen[column] = true
+		}
+		for _, field := range p.FieldList() {
+			column := columnName(field.Key)
+			if seen[column] {
+				// tags take precedence over fields with the same name
+				continue
+			}
+			columns = append(columns, quoteIdent(column))
+			values = append(values, field.Value)
+			seen[column] = true
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(p.Name()), strings.Join(columns, ", "), placeholders)
+		if _, err = tx.Exec(query, values...); err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// ensureColumns creates the measurement table and adds missing tag and field columns.
+func (s *SQLite) ensureColumns(tx *sql.Tx, p *write.Point) error {
+	table := p.Name()
+	known, ok := s.columns[table]
+	if !ok {
+		if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s INTEGER NOT NULL)", quoteIdent(table), quoteIdent(timeColumn))); err != nil {
+			return err
+		}
+		var err error
+		if known, err = tableColumns(tx, table); err != nil {
+			return err
+		}
+		s.columns[table] = known
+		if err = createIndex(tx, table, timeColumn); err != nil {
+			return err
+		}
+	}
+
+	addColumn := func(name string, sqlType string) error {
+		if known[name] {
+			return nil
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(table), quoteIdent(name), sqlType)); err != nil {
+			return err
+		}
+		known[name] = true
+		for _, indexed := range s.indexedColumns {
+			if indexed == name {
+				return createIndex(tx, table, name)
+			}
+		}
+		return nil
+	}
+
+	for _, tag := range p.TagList() {
+		if err := addColumn(columnName(tag.Key), "TEXT"); err != nil {
+			return err
+		}
+	}
+	for _, field := range p.FieldList() {
+		if err := addColumn(columnName(field.Key), sqliteType(field.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnName returns the column of a tag or field key.
+func columnName(key string) string {
+	if key == timeColumn {
+		return timeKeyColumn
+	}
+	return key
+}
+
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT name FROM pragma_table_info(%s)", quoteString(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+func createIndex(tx *sql.Tx, table string, column string) error {
+	_, err := tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", quoteIdent(table+"_"+column), quoteIdent(table), quoteIdent(column)))
+	return err
+}
+
+// sqliteType returns the column type for a field value as converted by write.NewPoint.
+func sqliteType(v interface{}) string {
+	switch v.(type) {
+	case int64, uint64, bool:
+		return "INTEGER"
+	case float64:
+		return "REAL"
+	default:
+		return "TEXT"
+	}
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteString(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,151 @@
+package sink
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// sqliteColumns returns the column names and declared types of a table.
+func sqliteColumns(t *testing.T, s *SQLite, table string) map[string]string {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT name, type FROM pragma_table_info(?)`, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns := map[string]string{}
+	for rows.Next() {
+		var name, sqlType string
+		if err := rows.Scan(&name, &sqlType); err != nil {
+			t.Fatal(err)
+		}
+		columns[name] = sqlType
+	}
+	return columns
+}
+
+func sqliteIndexes(t *testing.T, s *SQLite, table string) []string {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT name FROM pragma_index_list(?)`, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, name)
+	}
+	sort.Strings(indexes)
+	return indexes
+}
+
+func checkNoSinkError(t *testing.T, s Sink) {
+	t.Helper()
+	select {
+	case err := <-s.Errors():
+		t.Fatal(err)
+	default:
+	}
+}
+
+func TestSQLiteSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.db")
+	s, err := NewSQLite(path, TagKeys{Username: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1700000000, 0)
+
+	s.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"user": "alice", "season_slug": "Y8S4"}, map[string]interface{}{"mmr": 3000}, ts))
+	s.Flush()
+	// new fields add columns, a float in an integer column is kept as float
+	s.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"user": "bob", "season_slug": "Y8S4", "region": "emea"}, map[string]interface{}{"mmr": 2500.5, "rank_name": "Gold 2"}, ts))
+	s.Flush()
+	checkNoSinkError(t, s)
+
+	wantColumns := map[string]string{"time": "INTEGER", "user": "TEXT", "season_slug": "TEXT", "region": "TEXT", "mmr": "INTEGER", "rank_name": "TEXT"}
+	if columns := sqliteColumns(t, s, "ranked"); !reflect.DeepEqual(columns, wantColumns) {
+		t.Errorf("columns = %v, want %v", columns, wantColumns)
+	}
+	wantIndexes := []string{"ranked_season_slug", "ranked_time", "ranked_user"}
+	if indexes := sqliteIndexes(t, s, "ranked"); !reflect.DeepEqual(indexes, wantIndexes) {
+		t.Errorf("indexes = %v, want %v", indexes, wantIndexes)
+	}
+
+	var mmrs []interface{}
+	rows, err := s.db.Query(`SELECT mmr FROM ranked ORDER BY user`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var mmr interface{}
+		if err := rows.Scan(&mmr); err != nil {
+			t.Fatal(err)
+		}
+		mmrs = append(mmrs, mmr)
+	}
+	rows.Close()
+	if want := []interface{}{int64(3000), 2500.5}; !reflect.DeepEqual(mmrs, want) {
+		t.Errorf("mmr values = %#v, want %#v", mmrs, want)
+	}
+
+	// existing tables and columns are picked up after a restart
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewSQLite(path, TagKeys{Username: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.WritePoint(influxdb2.NewPoint("ranked", map[string]string{"user": "carol"}, map[string]interface{}{"mmr": 2000, "kills": 5}, ts))
+	s.Flush()
+	checkNoSinkError(t, s)
+	if columns := sqliteColumns(t, s, "ranked"); columns["kills"] != "INTEGER" || len(columns) != len(wantColumns)+1 {
+		t.Errorf("columns after restart = %v", columns)
+	}
+}
+
+func TestSQLiteTimeKey(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "stats.db"), TagKeys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ts := time.Unix(1700000000, 0)
+
+	s.WritePoint(influxdb2.NewPoint("events", map[string]string{"username": "alice"}, map[string]interface{}{"time": "evening"}, ts))
+	s.WritePoint(influxdb2.NewPoint("events", map[string]string{"username": "bob", "time": "morning"}, map[string]interface{}{"count": 1}, ts))
+	s.Flush()
+	checkNoSinkError(t, s)
+
+	rows, err := s.db.Query(`SELECT time, time_key FROM events ORDER BY username`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var pointTime int64
+		var key string
+		if err := rows.Scan(&pointTime, &key); err != nil {
+			t.Fatal(err)
+		}
+		if pointTime != ts.Unix() {
+			t.Errorf("time = %d, want the point time %d", pointTime, ts.Unix())
+		}
+		got = append(got, key)
+	}
+	if want := []string{"evening", "morning"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("time_key values = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/domain"
+	"github.com/rs/zerolog"
+	"github.com/stnokott/r6prom/config"
+	"github.com/stnokott/r6prom/constants"
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/sink"
+)
+
+// mqttRankStateFile is the file in the state directory holding the last published ranks.
+const mqttRankStateFile = "mqtt_ranks.json"
+
+// setupSinks creates all sinks enabled in the config. The returned function closes them.
+// In dry-run mode, points are only printed to stdout and no other sink is created.
+// Measurement names in the sink config refer to the original names and are rewritten to the written names.
+func setupSinks(conf config.Config, rewriter *metrics.Rewriter, logger *zerolog.Logger) (sink.Sink, func(), error) {
+	if conf.DryRun != "" {
+		dryRun, err := sink.NewDryRun(os.Stdout, conf.DryRun)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Info().Str("format", conf.DryRun).Msg("dry-run, printing points instead of writing")
+		return dryRun, func() {}, nil
+	}
+
+	tagKeys := sink.TagKeys{
+		Username:   rewriter.TagKey(sink.DefaultTagKeys.Username),
+		SeasonSlug: rewriter.TagKey(sink.DefaultTagKeys.SeasonSlug),
+		SeasonName: rewriter.TagKey(sink.DefaultTagKeys.SeasonName),
+	}
+	var sinks []sink.Sink
+	var closers []func()
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
+	if conf.InfluxURL != "" {
+		influxClient := influxdb2.NewClientWithOptions(conf.InfluxURL, conf.InfluxAuthToken, sink.InfluxOptions())
+		closers = append(closers, influxClient.Close)
+		health, err := influxClient.Health(context.Background())
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could not get InfluxDB health: %w", err)
+		}
+		if health.Status != domain.HealthCheckStatusPass {
+			closeAll()
+			return nil, nil, errors.New("InfluxDB server unhealthy")
+		}
+		logger.Info().Str("version", *health.Version).Str("msg", *health.Message).Str("db_name", health.Name).Msg("connected to InfluxDB")
+		sinks = append(sinks, influxClient.WriteAPI(conf.InfluxOrg, conf.InfluxBucket))
+	}
+
+	if conf.LineProtocolURL != "" {
+		lpSink, err := sink.NewLineProtocol(sink.LineProtocolOpts{
+			URL:             conf.LineProtocolURL,
+			Database:        conf.LineProtocolDatabase,
+			RetentionPolicy: conf.LineProtocolRetentionPolicy,
+			Username:        conf.LineProtocolUsername,
+			Password:        conf.LineProtocolPassword,
+		})
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could not create line protocol sink: %w", err)
+		}
+		closers = append(closers, lpSink.Close)
+		logger.Info().Str("url", conf.LineProtocolURL).Str("db", conf.LineProtocolDatabase).Msg("writing line protocol")
+		sinks = append(sinks, lpSink)
+	}
+
+	if conf.RemoteWriteURL != "" {
+		rwSink, err := sink.NewRemoteWrite(sink.RemoteWriteOpts{
+			URL:         conf.RemoteWriteURL,
+			Namespace:   conf.RemoteWriteNamespace,
+			Username:    conf.RemoteWriteUsername,
+			Password:    conf.RemoteWritePassword,
+			BearerToken: conf.RemoteWriteBearerToken,
+		})
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could not create remote-write sink: %w", err)
+		}
+		logger.Info().Str("url", conf.RemoteWriteURL).Msg("writing via Prometheus remote-write")
+		sinks = append(sinks, rwSink)
+	}
+
+	if conf.OTLPEndpoint != "" {
+		otlpSink, err := sink.NewOTLP(sink.OTLPOpts{
+			Endpoint:       conf.OTLPEndpoint,
+			Headers:        conf.OTLPHeaders,
+			ServiceName:    constants.NAME,
+			ServiceVersion: constants.VERSION,
+			TagKeys:        tagKeys,
+		})
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could not create OTLP sink: %w", err)
+		}
+		logger.Info().Str("endpoint", conf.OTLPEndpoint).Msg("exporting via OTLP")
+		sinks = append(sinks, otlpSink)
+	}
+
+	if conf.MQTTURL != "" {
+		mqttSink, err := sink.NewMQTT(sink.MQTTOpts{
+			URL:                conf.MQTTURL,
+			ClientID:           conf.MQTTClientID,
+			Username:           conf.MQTTUsername,
+			Password:           conf.MQTTPassword,
+			CAFile:             conf.MQTTCAFile,
+			InsecureSkipVerify: conf.MQTTInsecure,
+			TopicPrefix:        conf.MQTTTopicPrefix,
+			Fields:             rewriteFieldNames(rewriter, conf.MQTTFields),
+			Events:             rewriteMeasurements(rewriter, conf.MQTTEvents),
+			RankField:          rewriteFieldName(rewriter, conf.MQTTRankField),
+			RankName:           rankName,
+			DiscoveryPrefix:    conf.MQTTDiscoveryPrefix,
+			ServiceName:        constants.NAME,
+			TagKeys:            tagKeys,
+			RankStateFile:      filepath.Join(conf.StateDir, mqttRankStateFile),
+		})
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could not create MQTT sink: %w", err)
+		}
+		closers = append(closers, mqttSink.Close)
+		logger.Info().Str("url", conf.MQTTURL).Str("topic_prefix", conf.MQTTTopicPrefix).Msg("publishing to MQTT")
+		sinks = append(sinks, mqttSink)
+	}
+
+	if conf.SQLitePath != "" {
+		sqliteSink, err := sink.NewSQLite(conf.SQLitePath, tagKeys)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could not open SQLite sink: %w", err)
+		}
+		closers = append(closers, func() { _ = sqliteSink.Close() })
+		logger.Info().Str("path", conf.SQLitePath).Msg("writing to SQLite")
+		sinks = append(sinks, sqliteSink)
+	}
+
+	if conf.FileSinkDir != "" {
+		fileSink, err := sink.NewFile(conf.FileSinkDir, conf.FileSinkFormat, sink.RotateOpts{
+			MaxSize: int64(conf.FileSinkMaxSizeMB) * 1024 * 1024,
+			Daily:   conf.FileSinkRotateDaily,
+			Gzip:    conf.FileSinkGzip,
+		}, writtenSchema(conf, rewriter))
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could not create file sink: %w", err)
+		}
+		closers = append(closers, func() { _ = fileSink.Close() })
+		logger.Info().Str("dir", conf.FileSinkDir).Str("format", conf.FileSinkFormat).Msg("writing to files")
+		sinks = append(sinks, fileSink)
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil, errors.New("no sink configured, set at least one of INFLUX_URL, LINE_PROTOCOL_URL, REMOTE_WRITE_URL, OTLP_ENDPOINT, MQTT_URL, SQLITE_PATH or FILE_SINK_DIR")
+	}
+	return sink.NewMulti(sinks...), closeAll, nil
+}
+
+// rankName returns the display name of a rank ordinal like "Gold II".
+func rankName(ordinal int) string {
+	rank, err := metrics.RankFromOrdinal(ordinal)
+	if err != nil {
+		return ""
+	}
+	return rank.String()
+}
+
+// rewriteMeasurements returns the written names of measurements.
+func rewriteMeasurements(rewriter *metrics.Rewriter, measurements []string) []string {
+	result := make([]string, len(measurements))
+	for i, m := range measurements {
+		result[i] = rewriter.Measurement(m)
+	}
+	return result
+}
+
+// rewriteFieldName returns a "measurement.field" entry with the written measurement name.
+func rewriteFieldName(rewriter *metrics.Rewriter, qualified string) string {
+	measurement, field, ok := strings.Cut(qualified, ".")
+	if !ok {
+		return qualified
+	}
+	return rewriter.Measurement(measurement) + "." + field
+}
+
+// writtenSchema returns the schema of the points as they arrive at the sinks, with denied fields removed and names rewritten.
+func writtenSchema(conf config.Config, rewriter *metrics.Rewriter) metrics.Schema {
+	filter := metrics.NewFieldFilter(conf.FieldsAllow, conf.FieldsDeny)
+	return rewriter.ApplySchema(filter.ApplySchema(metrics.CurrentSchema()))
+}
+
+// rewriteFieldNames applies rewriteFieldName to all entries.
+func rewriteFieldNames(rewriter *metrics.Rewriter, qualified []string) []string {
+	result := make([]string, len(qualified))
+	for i, q := range qualified {
+		result[i] = rewriteFieldName(rewriter, q)
+	}
+	return result
+}
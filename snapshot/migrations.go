@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order, the schema version is the number of applied migrations.
+// Existing migrations must never be changed, schema changes are added as new migrations.
+var migrations = []string{
+	// 1: initial schema
+	`CREATE TABLE snapshots (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		username     TEXT    NOT NULL,
+		collector    TEXT    NOT NULL,
+		season_slug  TEXT    NOT NULL,
+		taken_at     INTEGER NOT NULL,
+		last_seen_at INTEGER NOT NULL,
+		raw_hash     TEXT    NOT NULL,
+		raw          TEXT    NOT NULL,
+		points       TEXT    NOT NULL
+	);
+	CREATE INDEX snapshots_key ON snapshots (username, collector, season_slug, taken_at);
+	CREATE INDEX snapshots_last_seen ON snapshots (last_seen_at);`,
+}
+
+// migrate brings the database schema to the latest version.
+func migrate(db *sql.DB) (from int, to int, err error) {
+	if _, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return
+	}
+	if err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&from); err != nil {
+		return
+	}
+	if from > len(migrations) {
+		err = fmt.Errorf("database schema version %d is newer than supported version %d", from, len(migrations))
+		return
+	}
+
+	for version := from + 1; version <= len(migrations); version++ {
+		if err = applyMigration(db, version); err != nil {
+			err = fmt.Errorf("migration to schema version %d failed: %w", version, err)
+			return
+		}
+	}
+	return from, len(migrations), nil
+}
+
+func applyMigration(db *sql.DB, version int) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(migrations[version-1]); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+		return
+	}
+	return tx.Commit()
+}
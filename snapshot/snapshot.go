@@ -0,0 +1,178 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	// registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// Point is a point derived from a raw stats response.
+type Point struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Time        time.Time              `json:"time"`
+}
+
+// Snapshot is a raw stats response of a single collector together with the points derived from it.
+type Snapshot struct {
+	Username   string
+	Collector  string
+	SeasonSlug string
+	// TakenAt is the time the response was first seen, LastSeenAt the time it was last seen unchanged.
+	TakenAt    time.Time
+	LastSeenAt time.Time
+	Raw        json.RawMessage
+	Points     []Point
+}
+
+// DB stores snapshots in an embedded SQLite database.
+// Unchanged responses are not stored again, only their last seen time is updated.
+type DB struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+// Open opens or creates the snapshot database at path and migrates it to the latest schema.
+// Snapshots not seen for longer than retention are removed by Prune, 0 keeps them forever.
+func Open(path string, retention time.Duration) (*DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only supports a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, _, err = migrate(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &DB{db: db, retention: retention}, nil
+}
+
+// SchemaVersion returns the current schema version of the database.
+func (d *DB) SchemaVersion() (version int, err error) {
+	err = d.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return
+}
+
+// Save stores a snapshot unless it equals the latest snapshot for the same user, collector and season.
+// It returns whether the raw response changed.
+func (d *DB) Save(username string, collector string, seasonSlug string, raw interface{}, points []Point, t time.Time) (changed bool, err error) {
+	rawJSON, err := json.Marshal(raw)
+	if err != nil {
+		return false, err
+	}
+	pointsJSON, err := json.Marshal(points)
+	if err != nil {
+		return false, err
+	}
+	hash := sha256.Sum256(rawJSON)
+	rawHash := hex.EncodeToString(hash[:])
+
+	var latestID int64
+	var latestHash string
+	err = d.db.QueryRow(
+		`SELECT id, raw_hash FROM snapshots WHERE username = ? AND collector = ? AND season_slug = ? ORDER BY taken_at DESC, id DESC LIMIT 1`,
+		username, collector, seasonSlug,
+	).Scan(&latestID, &latestHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	if err == nil && latestHash == rawHash {
+		_, err = d.db.Exec(`UPDATE snapshots SET last_seen_at = ? WHERE id = ?`, t.Unix(), latestID)
+		return false, err
+	}
+
+	_, err = d.db.Exec(
+		`INSERT INTO snapshots (username, collector, season_slug, taken_at, last_seen_at, raw_hash, raw, points) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		username, collector, seasonSlug, t.Unix(), t.Unix(), rawHash, string(rawJSON), string(pointsJSON),
+	)
+	return err == nil, err
+}
+
+// Latest returns the most recent snapshot for the given user, collector and season, nil if there is none.
+func (d *DB) Latest(username string, collector string, seasonSlug string) (*Snapshot, error) {
+	row := d.db.QueryRow(
+		`SELECT username, collector, season_slug, taken_at, last_seen_at, raw, points FROM snapshots
+		WHERE username = ? AND collector = ? AND season_slug = ? ORDER BY taken_at DESC, id DESC LIMIT 1`,
+		username, collector, seasonSlug,
+	)
+	s, err := scanSnapshot(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return s, err
+}
+
+// History returns all snapshots for the given user, collector and season taken since the given time, oldest first.
+func (d *DB) History(username string, collector string, seasonSlug string, since time.Time) ([]*Snapshot, error) {
+	rows, err := d.db.Query(
+		`SELECT username, collector, season_slug, taken_at, last_seen_at, raw, points FROM snapshots
+		WHERE username = ? AND collector = ? AND season_slug = ? AND last_seen_at >= ? ORDER BY taken_at, id`,
+		username, collector, seasonSlug, since.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*Snapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSnapshot(row scanner) (*Snapshot, error) {
+	var s Snapshot
+	var takenAt, lastSeenAt int64
+	var raw, points string
+	if err := row.Scan(&s.Username, &s.Collector, &s.SeasonSlug, &takenAt, &lastSeenAt, &raw, &points); err != nil {
+		return nil, err
+	}
+	s.TakenAt = time.Unix(takenAt, 0)
+	s.LastSeenAt = time.Unix(lastSeenAt, 0)
+	s.Raw = json.RawMessage(raw)
+	if err := json.Unmarshal([]byte(points), &s.Points); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Prune removes snapshots not seen within the retention period. The latest snapshot of each
+// user, collector and season is always kept so changes can still be detected.
+func (d *DB) Prune(now time.Time) (int64, error) {
+	if d.retention <= 0 {
+		return 0, nil
+	}
+	res, err := d.db.Exec(
+		`DELETE FROM snapshots WHERE last_seen_at < ? AND id NOT IN (
+			SELECT MAX(id) FROM snapshots GROUP BY username, collector, season_slug
+		)`,
+		now.Add(-d.retention).Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
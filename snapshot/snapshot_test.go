@@ -0,0 +1,150 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T, path string, retention time.Duration) *DB {
+	t.Helper()
+	db, err := Open(path, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestSaveDeduplicates(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "snapshots.db"), 0)
+	t1 := time.Unix(1700000000, 0)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+	points := []Point{{Measurement: "ranked", Tags: map[string]string{"season_slug": "Y8S4"}, Fields: map[string]interface{}{"mmr": 3000.0}, Time: t1}}
+
+	saves := []struct {
+		raw         interface{}
+		t           time.Time
+		wantChanged bool
+	}{
+		{map[string]int{"mmr": 3000}, t1, true},
+		// an identical payload only updates the last seen time
+		{map[string]int{"mmr": 3000}, t2, false},
+		{map[string]int{"mmr": 3100}, t3, true},
+	}
+	for i, s := range saves {
+		changed, err := db.Save("alice", "ranked", "Y8S4", s.raw, points, s.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != s.wantChanged {
+			t.Errorf("save %d: changed = %t, want %t", i, changed, s.wantChanged)
+		}
+	}
+
+	history, err := db.History("alice", "ranked", "Y8S4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(history))
+	}
+	if !history[0].TakenAt.Equal(t1) || !history[0].LastSeenAt.Equal(t2) {
+		t.Errorf("first snapshot taken at %v and last seen at %v, want %v and %v", history[0].TakenAt, history[0].LastSeenAt, t1, t2)
+	}
+	if string(history[1].Raw) != `{"mmr":3100}` || len(history[1].Points) != 1 {
+		t.Errorf("latest snapshot = %s with %d points", history[1].Raw, len(history[1].Points))
+	}
+
+	// other seasons are stored separately
+	if changed, err := db.Save("alice", "ranked", "Y8S3", map[string]int{"mmr": 3100}, points, t3); err != nil || !changed {
+		t.Errorf("save of another season: changed = %t, err = %v, want changed", changed, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "snapshots.db"), 24*time.Hour)
+	old := time.Unix(1700000000, 0)
+	now := old.Add(7 * 24 * time.Hour)
+
+	saves := []struct {
+		username string
+		mmr      int
+		t        time.Time
+	}{
+		{"alice", 3000, old},
+		{"alice", 3100, old.Add(time.Hour)},
+		// the latest snapshot of bob is beyond retention, but kept to detect changes
+		{"bob", 2000, old},
+		{"bob", 2100, old.Add(time.Hour)},
+		{"alice", 3200, now},
+	}
+	for _, s := range saves {
+		if _, err := db.Save(s.username, "ranked", "Y8S4", map[string]int{"mmr": s.mmr}, nil, s.t); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := db.Prune(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d snapshots, want 3", removed)
+	}
+	for username, want := range map[string]string{"alice": `{"mmr":3200}`, "bob": `{"mmr":2100}`} {
+		history, err := db.History(username, "ranked", "Y8S4", time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 || string(history[0].Raw) != want {
+			t.Errorf("%s: %d snapshots left, want only %s", username, len(history), want)
+		}
+	}
+
+	// unchanged snapshots are not pruned while they are still seen
+	if _, err := db.Save("bob", "ranked", "Y8S4", map[string]int{"mmr": 2100}, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := db.Prune(now.Add(time.Hour)); err != nil || removed != 0 {
+		t.Errorf("removed %d snapshots, err = %v, want none", removed, err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.db")
+
+	// fresh database
+	db, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != len(migrations) {
+		t.Errorf("schema version = %d, err = %v, want %d", version, err, len(migrations))
+	}
+	if _, err := db.Save("alice", "ranked", "Y8S4", map[string]int{"mmr": 3000}, nil, time.Unix(1700000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// existing database, migrations are not applied again and the data is kept
+	db = openTestDB(t, path, 0)
+	from, to, err := migrate(db.db)
+	if err != nil || from != len(migrations) || to != len(migrations) {
+		t.Errorf("migrate() = %d, %d, %v, want no migration at version %d", from, to, err, len(migrations))
+	}
+	if latest, err := db.Latest("alice", "ranked", "Y8S4"); err != nil || latest == nil {
+		t.Errorf("latest snapshot = %v, err = %v, want the saved snapshot", latest, err)
+	}
+
+	// databases of a newer version are rejected
+	if _, err := db.db.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, len(migrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := migrate(db.db); err == nil {
+		t.Error("migrate() of a newer schema succeeded")
+	}
+}
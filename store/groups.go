@@ -0,0 +1,32 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// sendGroupStats writes aggregated points for each configured group of users.
+func (s *Store) sendGroupStats(collected *runPoints) {
+	for group, members := range s.groups {
+		var points []*write.Point
+		for _, member := range members {
+			memberPoints, ok := collected.byUser[strings.ToLower(member)]
+			if !ok {
+				s.logger.Warn().Str("group", group).Str("username", member).Msg("no stats for group member in this run")
+				continue
+			}
+			points = append(points, memberPoints...)
+		}
+		if len(points) == 0 {
+			continue
+		}
+		aggregated := metrics.AggregatePoints(group, points)
+		for _, p := range aggregated {
+			// enrichment fields like ranked_pool are not aggregated, add them again
+			s.writePoint(s.enricher.Apply(p))
+		}
+		s.logger.Info().Str("group", group).Int("num_points", len(aggregated)).Msg("sent group stats")
+	}
+}
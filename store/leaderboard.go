@@ -0,0 +1,29 @@
+package store
+
+import (
+	"time"
+
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// sendLeaderboard computes the rankings among all observed users and writes them as leaderboard points.
+func (s *Store) sendLeaderboard(collected *runPoints, t time.Time) {
+	lb := metrics.BuildLeaderboard(collected.byUser, s.minRounds, t)
+	points := lb.Points()
+	for _, p := range points {
+		s.writePoint(p)
+	}
+
+	s.leaderboardMu.Lock()
+	s.leaderboard = lb
+	s.leaderboardMu.Unlock()
+
+	s.logger.Info().Int("num_boards", len(lb.Boards)).Int("num_points", len(points)).Msg("sent leaderboard")
+}
+
+// Leaderboard returns the leaderboard computed in the latest run, nil if no run has finished yet.
+func (s *Store) Leaderboard() *metrics.Leaderboard {
+	s.leaderboardMu.RLock()
+	defer s.leaderboardMu.RUnlock()
+	return s.leaderboard
+}
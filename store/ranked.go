@@ -0,0 +1,29 @@
+package store
+
+import (
+	"path/filepath"
+
+	"github.com/stnokott/r6prom/metrics"
+)
+
+const rankedFileName = "ranked.json"
+
+// loadRankedState restores the fingerprints of already written past ranked seasons from stateDir.
+func loadRankedState(stateDir string, ranked *metrics.RankedCollector) error {
+	fingerprints := map[string]string{}
+	if err := loadState(filepath.Join(stateDir, rankedFileName), &fingerprints); err != nil {
+		return err
+	}
+	ranked.RestoreFingerprints(fingerprints)
+	return nil
+}
+
+// saveRankedState persists the fingerprints of already written past ranked seasons.
+func (s *Store) saveRankedState() {
+	if s.readOnlyState {
+		return
+	}
+	if err := saveState(filepath.Join(s.stateDir, rankedFileName), s.ranked.Fingerprints()); err != nil {
+		s.logger.Err(err).Msg("could not save ranked state")
+	}
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// runPoints collects the points and raw responses of a single run per username,
+// used by all features which need to look at more than a single point.
+type runPoints struct {
+	mu     sync.Mutex
+	byUser map[string][]*write.Point
+	raw    map[string][]*metrics.RawStats
+	// failures is the number of users or collectors which could not be collected
+	failures int
+}
+
+func newRunPoints() *runPoints {
+	return &runPoints{
+		byUser: map[string][]*write.Point{},
+		raw:    map[string][]*metrics.RawStats{},
+	}
+}
+
+func (r *runPoints) add(username string, p *write.Point) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(username)
+	r.byUser[key] = append(r.byUser[key], p)
+}
+
+func (r *runPoints) addFailure() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures++
+}
+
+// complete reports whether all users and collectors were collected without error.
+func (r *runPoints) complete() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failures == 0
+}
+
+func (r *runPoints) addRaw(username string, raw *metrics.RawStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(username)
+	r.raw[key] = append(r.raw[key], raw)
+}
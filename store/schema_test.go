@@ -0,0 +1,115 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/enrich"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// TestSchemaMatchesTrackers checks the points written by the store itself against the current schema.
+func TestSchemaMatchesTrackers(t *testing.T) {
+	season := metrics.Season{Slug: "Y8S4", Name: "Deep Freeze"}
+	start := time.Unix(1700000000, 0)
+	mmrStart, mmrEnd := int64(3000), int64(3050)
+	session := &sessionState{
+		Username:   "alice",
+		SeasonSlug: season.Slug,
+		SeasonName: season.Name,
+		Start:      start,
+		LastChange: start.Add(time.Hour),
+		Baseline:   &userSnapshot{MMR: &mmrStart, Operators: map[string]int64{}, Maps: map[string]int64{}},
+		Last: userSnapshot{
+			MatchesPlayed: 3,
+			MatchesWon:    2,
+			MatchesLost:   1,
+			Kills:         20,
+			Deaths:        10,
+			MMR:           &mmrEnd,
+			Operators:     map[string]int64{"Ash": 5},
+			Maps:          map[string]int64{"Oregon": 2},
+		},
+	}
+
+	tracker, err := newStreakTracker(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := func(won int64) []*write.Point {
+		return []*write.Point{influxdb2.NewPoint(
+			"matches",
+			map[string]string{"season_slug": season.Slug, "username": "alice", "gamemode": "ranked"},
+			map[string]interface{}{"matches_won": won, "matches_lost": int64(0)},
+			start,
+		)}
+	}
+	// the first run only records the counters
+	tracker.update("alice", matches(0), season, start)
+	points := append(tracker.update("alice", matches(2), season, start), session.sessionPoint())
+
+	checkSchema(t, points, "sessions", "streaks", "streak_events")
+}
+
+// TestSchemaWithEnrichment checks that the tags and fields added by enrichment are part of the current schema,
+// for collected points as well as for the group aggregates, which are enriched again.
+func TestSchemaWithEnrichment(t *testing.T) {
+	enricher, err := enrich.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1700000000, 0)
+	user := func(tags map[string]string) map[string]string {
+		tags["season_slug"], tags["season_name"], tags["username"] = "Y8S4", "Deep Freeze", "alice"
+		return tags
+	}
+	collected := []*write.Point{
+		influxdb2.NewPoint("maps", user(map[string]string{"gamemode": "ranked", "map": "Oregon"}), map[string]interface{}{"matches_played": 2, "kills": 10, "rounds_played": 14}, ts),
+		influxdb2.NewPoint("bombsites", user(map[string]string{"gamemode": "ranked", "map": "Oregon", "role": "attacker", "bombsite": "Kitchen"}), map[string]interface{}{"kills": 3, "rounds_played": 4}, ts),
+		influxdb2.NewPoint("actions", user(map[string]string{"gamemode": "ranked", "role": "attacker", "operator": "Thatcher"}), map[string]interface{}{"kills": 5, "rounds_played": 8}, ts),
+		influxdb2.NewPoint("ranked", user(map[string]string{}), map[string]interface{}{"mmr": 3000, "rank": 20, "max_rank": 21}, ts),
+		influxdb2.NewPoint("ranked_combined", user(map[string]string{"source": "ubisoft"}), map[string]interface{}{"mmr": 3000, "rank": 20}, ts),
+		influxdb2.NewPoint("ranked_discrepancy", user(map[string]string{"source": "ubisoft", "secondary_source": "tabstats"}), map[string]interface{}{"mmr": 3000, "rank": 20, "secondary_rank": 19}, ts),
+	}
+	var points []*write.Point
+	for _, p := range collected {
+		points = append(points, enricher.Apply(p))
+	}
+	for _, p := range metrics.AggregatePoints("team", points) {
+		points = append(points, enricher.Apply(p))
+	}
+
+	enriched := map[string]string{"maps": "ranked_pool", "bombsites": "ranked_pool", "actions": "side", "ranked": "max_rank_name", "ranked_combined": "rank_name"}
+	for _, p := range points {
+		key, ok := enriched[p.Name()]
+		if !ok {
+			continue
+		}
+		_, hasTag := metrics.PointTags(p)[key]
+		_, hasField := metrics.PointFields(p)[key]
+		if !hasTag && !hasField {
+			t.Errorf("%s point was not enriched with %s", p.Name(), key)
+		}
+	}
+	checkSchema(t, points, "maps", "bombsites", "actions", "ranked", "ranked_combined", "ranked_discrepancy")
+}
+
+// checkSchema checks the stamped points against the current schema and that all given measurements were written.
+func checkSchema(t *testing.T, points []*write.Point, measurements ...string) {
+	t.Helper()
+	schema := metrics.CurrentSchema()
+	written := map[string]bool{}
+	for _, p := range points {
+		if err := schema.Check(metrics.StampSchemaVersion(p)); err != nil {
+			t.Error(err)
+		}
+		written[p.Name()] = true
+	}
+	for _, m := range measurements {
+		if !written[m] {
+			t.Errorf("no %s point written", m)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"path/filepath"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+const seasonFileName = "season.json"
+
+// seasonState is the persisted season of the latest run.
+type seasonState struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// loadSeason reads the season of the latest run before a restart from stateDir, nil if there is none.
+func loadSeason(stateDir string) (*metrics.Season, error) {
+	var state *seasonState
+	if err := loadState(filepath.Join(stateDir, seasonFileName), &state); err != nil {
+		return nil, err
+	}
+	if state == nil || state.Slug == "" {
+		return nil, nil
+	}
+	return &metrics.Season{Slug: state.Slug, Name: state.Name}, nil
+}
+
+// rollOver detects a season change since the last recorded season and records current afterwards.
+// On a change, sendFinal sends the final stats of the previous season and reports whether they were complete.
+// The current season is only recorded after the final stats were sent completely and flushed, so that they
+// are sent again on the next run if sending failed or the process stopped in between.
+func (s *Store) rollOver(current metrics.Season, t time.Time, sendFinal func(previous metrics.Season) bool) {
+	if previous, changed := s.checkSeasonChange(current); changed {
+		previous.Final = true
+		s.logger.Info().Str("season", previous.Slug).Msg("sending final stats of previous season")
+		if !sendFinal(previous) {
+			s.logger.Warn().Str("season", previous.Slug).Msg("final stats of previous season are incomplete, retrying on next run")
+			return
+		}
+		s.sendSeasonChange(previous, current, t)
+		s.sink.Flush()
+	}
+	s.recordSeason(current)
+}
+
+// checkSeasonChange marks current as the season of the latest run and returns the last recorded season if it differs.
+// The recorded season is persisted, so changes which happened while not running are detected on startup.
+// The very first run without any persisted state never reports a change.
+func (s *Store) checkSeasonChange(current metrics.Season) (previous metrics.Season, changed bool) {
+	s.seasonMu.Lock()
+	defer s.seasonMu.Unlock()
+	s.currentSeason = &current
+	if s.lastSeason != nil && s.lastSeason.Slug != current.Slug {
+		previous, changed = *s.lastSeason, true
+	}
+	return
+}
+
+// recordSeason persists current as the season whose stats were sent completely.
+func (s *Store) recordSeason(current metrics.Season) {
+	s.seasonMu.Lock()
+	defer s.seasonMu.Unlock()
+	if s.lastSeason != nil && *s.lastSeason == current {
+		return
+	}
+	s.lastSeason = &current
+	if s.readOnlyState {
+		return
+	}
+	state := seasonState{Slug: current.Slug, Name: current.Name}
+	if err := saveState(filepath.Join(s.stateDir, seasonFileName), state); err != nil {
+		s.logger.Err(err).Msg("could not save season state")
+	}
+}
+
+// CurrentSeason returns the season of the latest run, ok is false if no run has started yet.
+func (s *Store) CurrentSeason() (season metrics.Season, ok bool) {
+	s.seasonMu.RLock()
+	defer s.seasonMu.RUnlock()
+	if s.currentSeason == nil {
+		return metrics.Season{}, false
+	}
+	return *s.currentSeason, true
+}
+
+// sendSeasonChange writes a season_change event point.
+func (s *Store) sendSeasonChange(previous metrics.Season, current metrics.Season, t time.Time) {
+	s.logger.Info().
+		Str("previous", previous.Slug).
+		Str("current", current.Slug).
+		Msg("detected season change")
+
+	s.writePoint(influxdb2.NewPoint(
+		"season_change",
+		map[string]string{
+			"season_slug":          current.Slug,
+			"season_name":          current.Name,
+			"previous_season_slug": previous.Slug,
+			"previous_season_name": previous.Name,
+		},
+		map[string]interface{}{
+			"changed": true,
+		},
+		t,
+	))
+}
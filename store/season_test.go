@@ -0,0 +1,94 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/rs/zerolog"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+// recordingSink records the names of written points and the number of flushes.
+type recordingSink struct {
+	names   []string
+	flushes int
+}
+
+func (s *recordingSink) WritePoint(p *write.Point) {
+	s.names = append(s.names, p.Name())
+}
+
+func (s *recordingSink) Flush() {
+	s.flushes++
+}
+
+func (s *recordingSink) Errors() <-chan error {
+	return nil
+}
+
+// newSeasonTestStore creates a store with the season persisted in stateDir, as after a restart.
+func newSeasonTestStore(t *testing.T, stateDir string) (*Store, *recordingSink) {
+	t.Helper()
+	lastSeason, err := loadSeason(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zerolog.Nop()
+	rec := &recordingSink{}
+	return &Store{sink: rec, stateDir: stateDir, lastSeason: lastSeason, currentSeason: lastSeason, logger: &logger}, rec
+}
+
+func TestSeasonRollOver(t *testing.T) {
+	stateDir := t.TempDir()
+	y8s3 := metrics.Season{Slug: "Y8S3", Name: "Heavy Mettle"}
+	y8s4 := metrics.Season{Slug: "Y8S4", Name: "Deep Freeze"}
+	now := time.Unix(1700000000, 0)
+
+	var finals []metrics.Season
+	sendFinal := func(ok bool) func(metrics.Season) bool {
+		return func(previous metrics.Season) bool {
+			finals = append(finals, previous)
+			return ok
+		}
+	}
+
+	// the first run without state records the season without change
+	s, rec := newSeasonTestStore(t, stateDir)
+	if _, ok := s.CurrentSeason(); ok {
+		t.Error("current season known before the first run")
+	}
+	s.rollOver(y8s3, now, sendFinal(true))
+	if len(finals) != 0 || len(rec.names) != 0 {
+		t.Fatalf("first run sent final stats %v and points %v", finals, rec.names)
+	}
+
+	// a failed final send after a restart is retried on the next restart
+	s, rec = newSeasonTestStore(t, stateDir)
+	s.rollOver(y8s4, now, sendFinal(false))
+	if len(finals) != 1 || finals[0].Slug != y8s3.Slug || !finals[0].Final {
+		t.Fatalf("final stats sent for %v, want final Y8S3", finals)
+	}
+	if len(rec.names) != 0 {
+		t.Errorf("incomplete final send wrote %v", rec.names)
+	}
+	if current, ok := s.CurrentSeason(); !ok || current != y8s4 {
+		t.Errorf("current season = %v, want Y8S4", current)
+	}
+
+	s, rec = newSeasonTestStore(t, stateDir)
+	s.rollOver(y8s4, now, sendFinal(true))
+	if len(finals) != 2 || finals[1].Slug != y8s3.Slug {
+		t.Fatalf("final stats sent for %v, want Y8S3 again", finals)
+	}
+	if len(rec.names) != 1 || rec.names[0] != "season_change" || rec.flushes != 1 {
+		t.Errorf("wrote %v with %d flushes, want a flushed season_change", rec.names, rec.flushes)
+	}
+
+	// the rollover is recorded after the complete final send
+	s, rec = newSeasonTestStore(t, stateDir)
+	s.rollOver(y8s4, now, sendFinal(true))
+	if len(finals) != 2 || len(rec.names) != 0 {
+		t.Errorf("unchanged season sent final stats %v and points %v", finals, rec.names)
+	}
+}
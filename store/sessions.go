@@ -0,0 +1,280 @@
+package store
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+const (
+	sessionsFileName = "sessions.json"
+	// sessionTopN is the number of most played operators and maps written per session
+	sessionTopN = 3
+)
+
+// userSnapshot holds the counters of a user at a single point in time, used to compute session deltas.
+type userSnapshot struct {
+	MatchesPlayed int64            `json:"matches_played"`
+	MatchesWon    int64            `json:"matches_won"`
+	MatchesLost   int64            `json:"matches_lost"`
+	Kills         int64            `json:"kills"`
+	Deaths        int64            `json:"deaths"`
+	MMR           *int64           `json:"mmr,omitempty"`
+	Operators     map[string]int64 `json:"operators"`
+	Maps          map[string]int64 `json:"maps"`
+}
+
+// sessionState is the persisted session tracking state of a single user.
+type sessionState struct {
+	Username   string       `json:"username"`
+	SeasonSlug string       `json:"season_slug"`
+	SeasonName string       `json:"season_name"`
+	LastSeen   time.Time    `json:"last_seen"`
+	Last       userSnapshot `json:"last"`
+
+	Active     bool          `json:"active"`
+	Start      time.Time     `json:"start,omitempty"`
+	LastChange time.Time     `json:"last_change,omitempty"`
+	IdleRuns   int           `json:"idle_runs"`
+	Baseline   *userSnapshot `json:"baseline,omitempty"`
+}
+
+// sessionTracker detects gaming sessions from the changes in matches played between runs.
+// A session starts when matches_played increases and ends after idleRuns runs without change.
+type sessionTracker struct {
+	path     string
+	idleRuns int
+
+	mu     sync.Mutex
+	states map[string]*sessionState
+}
+
+func newSessionTracker(stateDir string, idleRuns int) (*sessionTracker, error) {
+	if idleRuns < 1 {
+		idleRuns = 1
+	}
+	t := &sessionTracker{
+		path:     filepath.Join(stateDir, sessionsFileName),
+		idleRuns: idleRuns,
+		states:   map[string]*sessionState{},
+	}
+	if err := loadState(t.path, &t.states); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// snapshotFromPoints extracts the session relevant counters from the points of a single user and run.
+// ok is false if the points do not contain the overall match counters.
+func snapshotFromPoints(points []*write.Point, seasonSlug string) (snap userSnapshot, username string, ok bool) {
+	snap.Operators = map[string]int64{}
+	snap.Maps = map[string]int64{}
+	var hasKD bool
+
+	for _, p := range points {
+		tags := metrics.PointTags(p)
+		if tags["season_slug"] != seasonSlug {
+			continue
+		}
+		fields := metrics.PointFields(p)
+		switch p.Name() {
+		case "matches":
+			if tags["gamemode"] != "all" {
+				continue
+			}
+			username = tags["username"]
+			snap.MatchesPlayed = intField(fields, "matches_played")
+			snap.MatchesWon = intField(fields, "matches_won")
+			snap.MatchesLost = intField(fields, "matches_lost")
+			ok = true
+			if _, exists := fields["kills"]; exists {
+				snap.Kills = intField(fields, "kills")
+				snap.Deaths = intField(fields, "deaths")
+				hasKD = true
+			}
+		case "maps":
+			if tags["gamemode"] == "all" {
+				snap.Maps[tags["map"]] = intField(fields, "matches_played")
+			}
+		case "actions":
+			if tags["gamemode"] == "all" && tags["role"] == "all" {
+				snap.Operators[tags["operator"]] = intField(fields, "rounds_played")
+			}
+		case "ranked":
+			mmr := intField(fields, "mmr")
+			snap.MMR = &mmr
+		}
+	}
+
+	if !hasKD {
+		// fall back to summing up kills and deaths over all maps
+		for _, p := range points {
+			tags := metrics.PointTags(p)
+			if p.Name() != "maps" || tags["gamemode"] != "all" || tags["season_slug"] != seasonSlug {
+				continue
+			}
+			fields := metrics.PointFields(p)
+			snap.Kills += intField(fields, "kills")
+			snap.Deaths += intField(fields, "deaths")
+		}
+	}
+	return
+}
+
+// update processes the snapshot of a user in the current run and returns a sessions point if a session ended.
+func (t *sessionTracker) update(key string, username string, season metrics.Season, snap userSnapshot, now time.Time) *write.Point {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.states[key]
+	if !exists || state.SeasonSlug != season.Slug || snap.MatchesPlayed < state.Last.MatchesPlayed {
+		// first run or counters were reset by a new season, end any active session and start over
+		var p *write.Point
+		if exists && state.Active {
+			p = state.sessionPoint()
+		}
+		t.states[key] = &sessionState{
+			Username:   username,
+			SeasonSlug: season.Slug,
+			SeasonName: season.Name,
+			LastSeen:   now,
+			Last:       snap,
+		}
+		return p
+	}
+
+	var p *write.Point
+	changed := snap.MatchesPlayed != state.Last.MatchesPlayed
+	switch {
+	case !state.Active && changed:
+		state.Active = true
+		// the session started some time after the previous run
+		state.Start = state.LastSeen
+		state.LastChange = now
+		state.IdleRuns = 0
+		baseline := state.Last
+		state.Baseline = &baseline
+	case state.Active && changed:
+		state.LastChange = now
+		state.IdleRuns = 0
+	case state.Active:
+		state.IdleRuns++
+	}
+
+	state.Username = username
+	state.Last = snap
+	state.LastSeen = now
+
+	if state.Active && state.IdleRuns >= t.idleRuns {
+		p = state.sessionPoint()
+		state.Active = false
+		state.Baseline = nil
+		state.IdleRuns = 0
+	}
+	return p
+}
+
+// sessionPoint creates the summary point of the active session, comparing the baseline to the last snapshot.
+func (state *sessionState) sessionPoint() *write.Point {
+	start, end := *state.Baseline, state.Last
+	fields := map[string]interface{}{
+		"start":            state.Start.Unix(),
+		"duration_seconds": int64(state.LastChange.Sub(state.Start).Seconds()),
+		"matches":          end.MatchesPlayed - start.MatchesPlayed,
+		"wins":             end.MatchesWon - start.MatchesWon,
+		"losses":           end.MatchesLost - start.MatchesLost,
+		"kills":            end.Kills - start.Kills,
+		"deaths":           end.Deaths - start.Deaths,
+	}
+	// adds kd_ratio, which is omitted for sessions without deaths like for all other points
+	metrics.AddDerivedFields(fields)
+	if start.MMR != nil && end.MMR != nil {
+		fields["mmr_start"] = *start.MMR
+		fields["mmr_end"] = *end.MMR
+		fields["mmr_delta"] = *end.MMR - *start.MMR
+	}
+	if top := topDeltas(start.Operators, end.Operators, sessionTopN); top != "" {
+		fields["top_operators"] = top
+	}
+	if top := topDeltas(start.Maps, end.Maps, sessionTopN); top != "" {
+		fields["top_maps"] = top
+	}
+
+	return influxdb2.NewPoint(
+		"sessions",
+		map[string]string{
+			"season_slug": state.SeasonSlug,
+			"season_name": state.SeasonName,
+			"username":    state.Username,
+		},
+		fields,
+		state.LastChange,
+	)
+}
+
+// topDeltas returns the comma-separated names with the highest increase between start and end.
+func topDeltas(start map[string]int64, end map[string]int64, n int) string {
+	type delta struct {
+		name  string
+		value int64
+	}
+	var deltas []delta
+	for name, value := range end {
+		if d := value - start[name]; d > 0 {
+			deltas = append(deltas, delta{name, d})
+		}
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].value != deltas[j].value {
+			return deltas[i].value > deltas[j].value
+		}
+		return deltas[i].name < deltas[j].name
+	})
+	if len(deltas) > n {
+		deltas = deltas[:n]
+	}
+	names := make([]string, len(deltas))
+	for i, d := range deltas {
+		names[i] = d.name
+	}
+	return strings.Join(names, ",")
+}
+
+func (t *sessionTracker) save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return saveState(t.path, t.states)
+}
+
+// intField returns a numeric field as integer, 0 if it is missing.
+func intField(fields map[string]interface{}, key string) int64 {
+	v, _ := metrics.FloatField(fields, key)
+	return int64(v)
+}
+
+// trackSessions updates the session state of all users and writes points for finished sessions.
+func (s *Store) trackSessions(collected *runPoints, season metrics.Season, t time.Time) {
+	for key, points := range collected.byUser {
+		snap, username, ok := snapshotFromPoints(points, season.Slug)
+		if !ok {
+			// incomplete data, don't count this run
+			continue
+		}
+		if p := s.sessions.update(key, username, season, snap, t); p != nil {
+			s.logger.Info().Str("username", username).Msg("session ended")
+			s.writePoint(p)
+		}
+	}
+	if s.readOnlyState {
+		return
+	}
+	if err := s.sessions.save(); err != nil {
+		s.logger.Err(err).Msg("could not save session state")
+	}
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stnokott/r6prom/metrics"
+)
+
+func sessionSnapshot(matches int64, kills int64, deaths int64, mmr int64) userSnapshot {
+	return userSnapshot{
+		MatchesPlayed: matches,
+		MatchesWon:    matches / 2,
+		MatchesLost:   matches - matches/2,
+		Kills:         kills,
+		Deaths:        deaths,
+		MMR:           &mmr,
+		Operators:     map[string]int64{"Ash": matches * 5},
+		Maps:          map[string]int64{"Oregon": matches},
+	}
+}
+
+func TestSessionTracker(t *testing.T) {
+	season := metrics.Season{Slug: "Y8S4", Name: "Deep Freeze"}
+	start := time.Unix(1700000000, 0)
+	at := func(run int) time.Time {
+		return start.Add(time.Duration(run) * 10 * time.Minute)
+	}
+	stateDir := t.TempDir()
+	tracker, err := newSessionTracker(stateDir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := []struct {
+		snap      userSnapshot
+		wantPoint bool
+	}{
+		// first run sets the baseline
+		{sessionSnapshot(10, 100, 50, 3000), false},
+		{sessionSnapshot(10, 100, 50, 3000), false},
+		// session starts
+		{sessionSnapshot(12, 110, 52, 3050), false},
+		{sessionSnapshot(14, 125, 54, 3100), false},
+		// first idle run
+		{sessionSnapshot(14, 125, 54, 3100), false},
+	}
+	for i, run := range runs {
+		if p := tracker.update("user", "alice", season, run.snap, at(i)); (p != nil) != run.wantPoint {
+			t.Fatalf("run %d: point = %v, want point %t", i, p, run.wantPoint)
+		}
+	}
+	if state := tracker.states["user"]; !state.Active || !state.Start.Equal(at(1)) || state.IdleRuns != 1 {
+		t.Fatalf("state = %+v, want active session since run 1 with one idle run", state)
+	}
+
+	// the session continues after a restart
+	if err := tracker.save(); err != nil {
+		t.Fatal(err)
+	}
+	tracker, err = newSessionTracker(stateDir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// second idle run ends the session
+	p := tracker.update("user", "alice", season, sessionSnapshot(14, 125, 54, 3100), at(5))
+	if p == nil {
+		t.Fatal("session did not end after the idle timeout")
+	}
+	want := map[string]interface{}{
+		"start":            at(1).Unix(),
+		"duration_seconds": int64(at(3).Sub(at(1)).Seconds()),
+		"matches":          int64(4),
+		"wins":             int64(2),
+		"losses":           int64(2),
+		"kills":            int64(25),
+		"deaths":           int64(4),
+		"kd_ratio":         6.25,
+		"mmr_start":        int64(3000),
+		"mmr_end":          int64(3100),
+		"mmr_delta":        int64(100),
+		"top_operators":    "Ash",
+		"top_maps":         "Oregon",
+	}
+	fields := metrics.PointFields(p)
+	if len(fields) != len(want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("%s = %v, want %v", k, fields[k], v)
+		}
+	}
+	if !p.Time().Equal(at(3)) {
+		t.Errorf("session point at %v, want the last change %v", p.Time(), at(3))
+	}
+	if state := tracker.states["user"]; state.Active || state.Baseline != nil {
+		t.Errorf("state = %+v, want inactive session", state)
+	}
+}
+
+func TestSessionPointWithoutDeaths(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	baseline := sessionSnapshot(10, 100, 50, 3000)
+	state := &sessionState{
+		Username:   "alice",
+		SeasonSlug: "Y8S4",
+		Start:      start,
+		LastChange: start.Add(time.Hour),
+		Baseline:   &baseline,
+		Last:       sessionSnapshot(11, 105, 50, 3020),
+	}
+	fields := metrics.PointFields(state.sessionPoint())
+	if _, ok := fields["kd_ratio"]; ok {
+		t.Errorf("kd_ratio = %v, want it omitted without deaths", fields["kd_ratio"])
+	}
+	if fields["kills"] != int64(5) {
+		t.Errorf("kills = %v, want 5", fields["kills"])
+	}
+}
+
+func TestSessionTrackerSeasonReset(t *testing.T) {
+	tracker, err := newSessionTracker(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	y8s3 := metrics.Season{Slug: "Y8S3", Name: "Heavy Mettle"}
+	y8s4 := metrics.Season{Slug: "Y8S4", Name: "Deep Freeze"}
+
+	tracker.update("user", "alice", y8s3, sessionSnapshot(10, 100, 50, 3000), now)
+	tracker.update("user", "alice", y8s3, sessionSnapshot(12, 110, 52, 3050), now.Add(time.Hour))
+	// the active session ends with the season, the reset counters are the new baseline
+	p := tracker.update("user", "alice", y8s4, sessionSnapshot(0, 0, 0, 2500), now.Add(2*time.Hour))
+	if p == nil || metrics.PointTags(p)["season_slug"] != y8s3.Slug {
+		t.Fatalf("point = %v, want session of Y8S3", p)
+	}
+	if p := tracker.update("user", "alice", y8s4, sessionSnapshot(1, 5, 1, 2520), now.Add(3*time.Hour)); p != nil {
+		t.Errorf("point = %v, want none for a new session", p)
+	}
+	if state := tracker.states["user"]; !state.Active || state.Baseline.MatchesPlayed != 0 {
+		t.Errorf("state = %+v, want active session from the reset counters", state)
+	}
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/snapshot"
+)
+
+// saveSnapshots stores the raw responses of a run together with their derived points.
+func (s *Store) saveSnapshots(collected *runPoints, season metrics.Season, t time.Time) {
+	for key, raws := range collected.raw {
+		for _, raw := range raws {
+			measurements := make(map[string]bool, len(raw.Measurements))
+			for _, m := range raw.Measurements {
+				measurements[m] = true
+			}
+			var points []snapshot.Point
+			for _, p := range collected.byUser[key] {
+				if measurements[p.Name()] {
+					points = append(points, snapshot.Point{
+						Measurement: p.Name(),
+						Tags:        metrics.PointTags(p),
+						Fields:      metrics.PointFields(p),
+						Time:        p.Time(),
+					})
+				}
+			}
+
+			changed, err := s.snapshots.Save(key, raw.Collector, season.Slug, raw.Data, points, t)
+			if err != nil {
+				s.logger.Err(err).Str("username", key).Str("collector", raw.Collector).Msg("could not save snapshot")
+				continue
+			}
+			if changed {
+				s.logger.Debug().Str("username", key).Str("collector", raw.Collector).Msg("saved changed snapshot")
+			}
+		}
+	}
+}
+
+// pruneSnapshots removes snapshots older than the configured retention.
+func (s *Store) pruneSnapshots(t time.Time) {
+	n, err := s.snapshots.Prune(t)
+	if err != nil {
+		s.logger.Err(err).Msg("could not prune snapshots")
+		return
+	}
+	if n > 0 {
+		s.logger.Info().Int64("count", n).Msg("pruned old snapshots")
+	}
+}
+
+// LatestSnapshot returns the latest snapshot of a collector for the given user and season, nil if there is none
+// or snapshots are disabled.
+func (s *Store) LatestSnapshot(username string, collector string, seasonSlug string) (*snapshot.Snapshot, error) {
+	if s.snapshots == nil {
+		return nil, nil
+	}
+	return s.snapshots.Latest(strings.ToLower(username), collector, seasonSlug)
+}
+
+// SnapshotHistory returns the snapshots of a collector for the given user and season taken since the given time,
+// oldest first. It returns nil if snapshots are disabled.
+func (s *Store) SnapshotHistory(username string, collector string, seasonSlug string, since time.Time) ([]*snapshot.Snapshot, error) {
+	if s.snapshots == nil {
+		return nil, nil
+	}
+	return s.snapshots.History(strings.ToLower(username), collector, seasonSlug, since)
+}
@@ -1,44 +1,133 @@
 package store
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron"
-	influxapi "github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 
 	"github.com/rs/zerolog"
 	"github.com/stnokott/r6api"
 	"github.com/stnokott/r6api/types/metadata"
+	"github.com/stnokott/r6prom/enrich"
 	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/sink"
+	"github.com/stnokott/r6prom/snapshot"
 )
 
 type Store struct {
 	usernames []string
 	api       *r6api.R6API
-	influxAPI influxapi.WriteAPI
-	scheduler *gocron.Scheduler
-	logger    *zerolog.Logger
+	sink      sink.Sink
+	filter    *metrics.FieldFilter
+	rewriter  *metrics.Rewriter
+	groups    map[string][]string
+	minRounds int
+	senders   []metrics.StatSenderFunc
+	tabStats  *metrics.TabStatsClient
+	ranked    *metrics.RankedCollector
+	sessions  *sessionTracker
+	streaks   *streakTracker
+	snapshots *snapshot.DB
+	normalize *enrich.Normalizer
+	enricher  *enrich.Enricher
+	stateDir  string
+	// lastSeason is the persisted season whose stats were sent completely, used to detect season changes
+	lastSeason *metrics.Season
+	// currentSeason is the season of the latest run
+	currentSeason *metrics.Season
+	seasonMu      sync.RWMutex
+	scheduler     *gocron.Scheduler
+	logger        *zerolog.Logger
+
+	// readOnlyState prevents saving state and snapshots
+	readOnlyState bool
+
+	leaderboardMu sync.RWMutex
+	leaderboard   *metrics.Leaderboard
 }
 
 type Opts struct {
 	// ObservedUsernames specifies the Uplay usernames to track metrics for
 	ObservedUsernames []string
-	// InfluxClient handles the connection with the InfluxDB v2
-	InfluxWriteAPI influxapi.WriteAPI
+	// Sink receives all written points, e.g. the InfluxDB v2 WriteAPI
+	Sink sink.Sink
 	// RefreshCron defines the interval at which the application checks for new stats
 	RefreshCron string
+	// FieldFilter restricts the fields written to InfluxDB, may be nil
+	FieldFilter *metrics.FieldFilter
+	// Rewriter renames measurements and tags and adds static tags when writing, may be nil
+	Rewriter *metrics.Rewriter
+	// UserGroups maps group names to observed usernames for which aggregated stats are written
+	UserGroups map[string][]string
+	// LeaderboardMinRounds is the number of rounds a user needs to have played to appear in a leaderboard category
+	LeaderboardMinRounds int
+	// TabStatsClient is used to query ranked stats from tabstats
+	TabStatsClient *metrics.TabStatsClient
+	// RankedCollector sends the ranked history
+	RankedCollector *metrics.RankedCollector
+	// RankedReconciler combines the ranked providers
+	RankedReconciler *metrics.RankedReconciler
+	// StateDir is the directory where state is persisted across restarts
+	StateDir string
+	// ReadOnlyState loads the persisted state, but never saves it or any snapshots, e.g. for dry runs
+	ReadOnlyState bool
+	// SessionIdleRuns is the number of runs without new matches after which a session ends
+	SessionIdleRuns int
+	// StreakEventLength is the win or loss streak length from which streak events are written, 0 disables events
+	StreakEventLength int
+	// Snapshots stores raw responses, disabled if nil
+	Snapshots *snapshot.DB
+	// Normalizer maps names in tags to canonical names, disabled if nil
+	Normalizer *enrich.Normalizer
+	// Enricher adds static metadata to collected points, disabled if nil
+	Enricher *enrich.Enricher
 }
 
 func New(api *r6api.R6API, logger *zerolog.Logger, opts Opts) (*Store, error) {
 	sched := gocron.NewScheduler(time.Local)
 
+	sessions, err := newSessionTracker(opts.StateDir, opts.SessionIdleRuns)
+	if err != nil {
+		return nil, fmt.Errorf("could not load session state: %w", err)
+	}
+	streaks, err := newStreakTracker(opts.StateDir, opts.StreakEventLength)
+	if err != nil {
+		return nil, fmt.Errorf("could not load streak state: %w", err)
+	}
+	if err := loadRankedState(opts.StateDir, opts.RankedCollector); err != nil {
+		return nil, fmt.Errorf("could not load ranked state: %w", err)
+	}
+	lastSeason, err := loadSeason(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load season state: %w", err)
+	}
+
 	store := &Store{
 		usernames: opts.ObservedUsernames,
 		api:       api,
-		influxAPI: opts.InfluxWriteAPI,
+		sink:      opts.Sink,
+		filter:    opts.FieldFilter,
+		rewriter:  opts.Rewriter,
+		groups:    opts.UserGroups,
+		minRounds: opts.LeaderboardMinRounds,
+		senders:   metrics.AllSenders(opts.RankedCollector, opts.TabStatsClient, opts.RankedReconciler),
+		tabStats:  opts.TabStatsClient,
+		ranked:    opts.RankedCollector,
+		sessions:  sessions,
+		streaks:   streaks,
+		snapshots: opts.Snapshots,
+		normalize: opts.Normalizer,
+		enricher:  opts.Enricher,
+		stateDir:  opts.StateDir,
 		scheduler: sched,
 		logger:    logger,
+
+		lastSeason:    lastSeason,
+		currentSeason: lastSeason,
+		readOnlyState: opts.ReadOnlyState,
 	}
 
 	if _, err := sched.Cron(opts.RefreshCron).Do(store.sendAll); err != nil {
@@ -70,6 +159,13 @@ func (s *Store) RunAsync() {
 	s.onStart()
 }
 
+// Usernames returns the observed usernames.
+func (s *Store) Usernames() []string {
+	usernames := make([]string, len(s.usernames))
+	copy(usernames, s.usernames)
+	return usernames
+}
+
 func (s *Store) onStart() {
 	_, next := s.scheduler.NextRun()
 	s.logger.Info().
@@ -84,7 +180,7 @@ func (s *Store) sendAll() {
 		return
 	}
 	defer func() {
-		s.influxAPI.Flush()
+		s.sink.Flush()
 		_, nextRun := s.scheduler.NextRun()
 		s.logger.Info().Msgf("flushed stats, next run at %v", nextRun)
 	}()
@@ -94,32 +190,72 @@ func (s *Store) sendAll() {
 		return
 	}
 
+	season, err := metrics.LatestSeason(meta)
+	if err != nil {
+		s.logger.Err(err).Msg("could not determine current season")
+		return
+	}
+
+	s.tabStats.ResetCache()
+	s.ranked.ResetCache()
 	now := time.Now()
+	if r, ok := s.sink.(sink.SeasonRegistry); ok {
+		r.RegisterSeason(season.Slug)
+	}
+	s.rollOver(season, now, func(previous metrics.Season) bool {
+		return s.sendSeason(meta, previous, now).complete()
+	})
+
+	collected := s.sendSeason(meta, season, now)
+	s.saveRankedState()
+	s.sendLeaderboard(collected, now)
+	s.trackSessions(collected, season, now)
+	s.trackStreaks(collected, season, now)
+	if s.snapshots != nil && !s.readOnlyState {
+		s.pruneSnapshots(now)
+	}
+}
+
+// sendSeason sends the stats of all users and groups for the given season.
+func (s *Store) sendSeason(meta *metadata.Metadata, season metrics.Season, t time.Time) *runPoints {
+	collected := newRunPoints()
 	var wg sync.WaitGroup
 
 	for _, username := range s.usernames {
 		wg.Add(1)
 		go func(username string) {
-			s.logger.Info().Str("username", username).Msgf("processing user %s", username)
-			s.sendUserStats(username, meta, now)
+			s.logger.Info().Str("username", username).Str("season", season.Slug).Msgf("processing user %s", username)
+			s.sendUserStats(username, meta, season, t, collected)
 			wg.Done()
 		}(username)
 	}
 	wg.Wait()
+
+	if len(s.groups) > 0 {
+		s.sendGroupStats(collected)
+	}
+	if s.snapshots != nil && !s.readOnlyState {
+		s.saveSnapshots(collected, season, t)
+	}
+	return collected
 }
 
-func (s *Store) sendUserStats(username string, meta *metadata.Metadata, t time.Time) {
+func (s *Store) sendUserStats(username string, meta *metadata.Metadata, season metrics.Season, t time.Time, collected *runPoints) {
 	profile, err := s.api.ResolveUser(username)
 	if err != nil {
 		s.logger.Err(err).Msg("could not resolve profile")
+		collected.addFailure()
 		return
 	}
+	if r, ok := s.sink.(sink.ProfileRegistry); ok {
+		r.RegisterProfile(profile.Name, profile.ProfileID)
+	}
 
-	running := len(metrics.AllSenders)
+	running := len(s.senders)
 	chData := make(chan metrics.StatResponse, 10)
 
-	for _, f := range metrics.AllSenders {
-		go f(s.api, profile, meta, t, chData)
+	for _, f := range s.senders {
+		go f(s.api, profile, meta, season, t, chData)
 	}
 
 	for running > 0 {
@@ -128,12 +264,27 @@ func (s *Store) sendUserStats(username string, meta *metadata.Metadata, t time.T
 			running -= 1
 		} else if data.Err != nil {
 			s.logger.Err(data.Err).Msg("error sending statistics")
+			collected.addFailure()
 			running -= 1
+		} else if data.Warning != nil {
+			s.logger.Warn().Err(data.Warning).Str("username", username).Msg("error sending statistics, using fallback")
 		} else if data.P != nil {
-			s.influxAPI.WritePoint(data.P)
+			p := s.enricher.Apply(s.normalize.Apply(data.P))
+			collected.add(username, p)
+			s.writePoint(p)
+		} else if data.Raw != nil {
+			collected.addRaw(username, data.Raw)
 		} else {
 			s.logger.Warn().Msg("got invalid data from data channel")
 		}
 	}
 	close(chData)
 }
+
+// writePoint adds the schema version, applies the field filter and the rewriter and writes the point to the sink.
+// The version is added first so it can be removed by the filter like any other field.
+func (s *Store) writePoint(p *write.Point) {
+	if p = s.filter.Apply(metrics.StampSchemaVersion(p)); p != nil {
+		s.sink.WritePoint(s.rewriter.Apply(p))
+	}
+}
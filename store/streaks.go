@@ -0,0 +1,178 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+const streaksFileName = "streaks.json"
+
+// streakState is the persisted streak state of a single user and game mode.
+type streakState struct {
+	// SeasonSlug is the season of the match counters
+	SeasonSlug  string `json:"season_slug"`
+	MatchesWon  int64  `json:"matches_won"`
+	MatchesLost int64  `json:"matches_lost"`
+
+	CurrentWins   int64 `json:"current_wins"`
+	CurrentLosses int64 `json:"current_losses"`
+	LongestWins   int64 `json:"longest_wins"`
+	LongestLosses int64 `json:"longest_losses"`
+}
+
+// streakTracker tracks win and loss streaks from the changes in matches won and lost between runs.
+// If both wins and losses increased within one run, their order is unknown and both current streaks are reset.
+type streakTracker struct {
+	path        string
+	eventLength int64
+
+	mu sync.Mutex
+	// states maps username to game mode to state
+	states map[string]map[string]*streakState
+}
+
+func newStreakTracker(stateDir string, eventLength int) (*streakTracker, error) {
+	t := &streakTracker{
+		path:        filepath.Join(stateDir, streaksFileName),
+		eventLength: int64(eventLength),
+		states:      map[string]map[string]*streakState{},
+	}
+	if err := loadState(t.path, &t.states); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// update processes the matches points of a user in the current run and returns the streaks and streak event points.
+func (t *streakTracker) update(key string, points []*write.Point, season metrics.Season, now time.Time) []*write.Point {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	userStates, ok := t.states[key]
+	if !ok {
+		userStates = map[string]*streakState{}
+		t.states[key] = userStates
+	}
+
+	var result []*write.Point
+	for _, p := range points {
+		tags := metrics.PointTags(p)
+		if p.Name() != "matches" || tags["season_slug"] != season.Slug {
+			continue
+		}
+		fields := metrics.PointFields(p)
+		won, lost := intField(fields, "matches_won"), intField(fields, "matches_lost")
+		gamemode := tags["gamemode"]
+
+		state, exists := userStates[gamemode]
+		if !exists {
+			userStates[gamemode] = &streakState{SeasonSlug: season.Slug, MatchesWon: won, MatchesLost: lost}
+			continue
+		}
+		if state.SeasonSlug != season.Slug {
+			// counters were reset by a new season, even if they already exceed the previous ones.
+			// Start over from the new counters, streaks continue.
+			state.SeasonSlug, state.MatchesWon, state.MatchesLost = season.Slug, won, lost
+			continue
+		}
+
+		wins, losses := won-state.MatchesWon, lost-state.MatchesLost
+		state.MatchesWon, state.MatchesLost = won, lost
+		previousWins, previousLosses := state.CurrentWins, state.CurrentLosses
+		switch {
+		case wins < 0 || losses < 0:
+			// counters decreased without a season change, e.g. after a correction by the API
+			continue
+		case wins > 0 && losses > 0:
+			// the order of the matches is unknown, so neither streak can be continued reliably
+			state.CurrentWins, state.CurrentLosses = 0, 0
+		case wins > 0:
+			state.CurrentWins += wins
+			state.CurrentLosses = 0
+		case losses > 0:
+			state.CurrentLosses += losses
+			state.CurrentWins = 0
+		}
+		if state.CurrentWins > state.LongestWins {
+			state.LongestWins = state.CurrentWins
+		}
+		if state.CurrentLosses > state.LongestLosses {
+			state.LongestLosses = state.CurrentLosses
+		}
+
+		streakTags := map[string]string{
+			"season_slug": season.Slug,
+			"season_name": season.Name,
+			"username":    tags["username"],
+			"gamemode":    gamemode,
+		}
+		result = append(result, influxdb2.NewPoint(
+			"streaks",
+			streakTags,
+			map[string]interface{}{
+				"current_win_streak":  state.CurrentWins,
+				"current_loss_streak": state.CurrentLosses,
+				"longest_win_streak":  state.LongestWins,
+				"longest_loss_streak": state.LongestLosses,
+			},
+			now,
+		))
+
+		// events are only written once per streak, when it reaches the event length
+		if t.eventLength > 0 {
+			if previousWins < t.eventLength && state.CurrentWins >= t.eventLength {
+				result = append(result, streakEvent(streakTags, "win", state.CurrentWins, now))
+			}
+			if previousLosses < t.eventLength && state.CurrentLosses >= t.eventLength {
+				result = append(result, streakEvent(streakTags, "loss", state.CurrentLosses, now))
+			}
+		}
+	}
+	return result
+}
+
+// streakEvent creates a streak_events point for a streak which reached the configured length.
+func streakEvent(srcTags map[string]string, streakType string, length int64, t time.Time) *write.Point {
+	tags := make(map[string]string, len(srcTags)+1)
+	for k, v := range srcTags {
+		tags[k] = v
+	}
+	tags["type"] = streakType
+	return influxdb2.NewPoint(
+		"streak_events",
+		tags,
+		map[string]interface{}{
+			"length": length,
+		},
+		t,
+	)
+}
+
+func (t *streakTracker) save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return saveState(t.path, t.states)
+}
+
+// trackStreaks updates the streaks of all users and writes streaks and streak event points.
+func (s *Store) trackStreaks(collected *runPoints, season metrics.Season, t time.Time) {
+	for key, points := range collected.byUser {
+		for _, p := range s.streaks.update(key, points, season, t) {
+			if p.Name() == "streak_events" {
+				s.logger.Info().Str("username", key).Msg("streak reached event length")
+			}
+			s.writePoint(p)
+		}
+	}
+	if s.readOnlyState {
+		return
+	}
+	if err := s.streaks.save(); err != nil {
+		s.logger.Err(err).Msg("could not save streak state")
+	}
+}
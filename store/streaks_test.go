@@ -0,0 +1,97 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+func TestStreakTrackerEvents(t *testing.T) {
+	season := metrics.Season{Slug: "Y8S4", Name: "Deep Freeze"}
+	tracker, err := newStreakTracker(t.TempDir(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := []struct {
+		won, lost  int64
+		wantWins   int64
+		wantLosses int64
+		wantEvents []string
+	}{
+		{0, 0, 0, 0, nil},
+		{2, 0, 2, 0, nil},
+		{3, 0, 3, 0, []string{"win"}},
+		{4, 0, 4, 0, nil},
+		{4, 3, 0, 3, []string{"loss"}},
+		{5, 4, 0, 0, nil},
+		{8, 4, 3, 0, []string{"win"}},
+	}
+	now := time.Unix(1700000000, 0)
+	for i, run := range runs {
+		points := []*write.Point{influxdb2.NewPoint(
+			"matches",
+			map[string]string{"season_slug": season.Slug, "username": "user", "gamemode": "ranked"},
+			map[string]interface{}{"matches_won": run.won, "matches_lost": run.lost},
+			now,
+		)}
+		var events []string
+		for _, p := range tracker.update("user", points, season, now) {
+			switch p.Name() {
+			case "streak_events":
+				events = append(events, metrics.PointTags(p)["type"])
+			case "streaks":
+				fields := metrics.PointFields(p)
+				if fields["current_win_streak"] != run.wantWins || fields["current_loss_streak"] != run.wantLosses {
+					t.Errorf("run %d: streaks = %v, want %d wins and %d losses", i, fields, run.wantWins, run.wantLosses)
+				}
+			}
+		}
+		if len(events) != len(run.wantEvents) || (len(events) > 0 && events[0] != run.wantEvents[0]) {
+			t.Errorf("run %d: events = %v, want %v", i, events, run.wantEvents)
+		}
+	}
+}
+
+func TestStreakTrackerSeasonRollOver(t *testing.T) {
+	tracker, err := newStreakTracker(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y8s3 := metrics.Season{Slug: "Y8S3", Name: "Heavy Mettle"}
+	y8s4 := metrics.Season{Slug: "Y8S4", Name: "Deep Freeze"}
+	now := time.Unix(1700000000, 0)
+
+	runs := []struct {
+		season     metrics.Season
+		won, lost  int64
+		wantPoints bool
+		wantWins   int64
+	}{
+		{y8s3, 10, 5, false, 0},
+		{y8s3, 13, 5, true, 3},
+		// the counters of the new season are not comparable to the previous ones, even if they are higher
+		{y8s4, 15, 6, false, 0},
+		{y8s4, 16, 6, true, 4},
+	}
+	for i, run := range runs {
+		points := []*write.Point{influxdb2.NewPoint(
+			"matches",
+			map[string]string{"season_slug": run.season.Slug, "username": "user", "gamemode": "ranked"},
+			map[string]interface{}{"matches_won": run.won, "matches_lost": run.lost},
+			now,
+		)}
+		result := tracker.update("user", points, run.season, now)
+		if (len(result) > 0) != run.wantPoints {
+			t.Fatalf("run %d: points = %v, want points %t", i, result, run.wantPoints)
+		}
+		if len(result) > 0 {
+			if fields := metrics.PointFields(result[0]); fields["current_win_streak"] != run.wantWins || fields["current_loss_streak"] != int64(0) {
+				t.Errorf("run %d: streaks = %v, want %d wins", i, fields, run.wantWins)
+			}
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/snapshot"
+)
+
+// topCount is the number of operators and maps shown on a player page.
+const topCount = 5
+
+// chartPeriod is the time range shown by the MMR chart.
+const chartPeriod = 30 * 24 * time.Hour
+
+// overviewGamemode and overviewRole select the stats shown on player pages.
+const (
+	overviewGamemode = "all"
+	overviewRole     = "all"
+)
+
+// playerView is the data shown for a single player.
+type playerView struct {
+	Username     string
+	Season       metrics.Season
+	Ranked       *rankedView
+	Matches      []matchesView
+	TopOperators []statRow
+	TopMaps      []statRow
+	Chart        *chart
+	UpdatedAt    time.Time
+}
+
+type rankedView struct {
+	MMR     int64
+	MaxMMR  int64
+	Rank    string
+	MaxRank string
+	Wins    int64
+	Losses  int64
+}
+
+type matchesView struct {
+	Gamemode string
+	Played   int64
+	Won      int64
+	Lost     int64
+	WinRate  float64
+}
+
+// statRow is a single operator or map in a top list.
+type statRow struct {
+	Name    string
+	Played  int64
+	KD      float64
+	WinRate float64
+}
+
+// buildPlayer collects the data of a player from the latest snapshots of the current season.
+func (s *Server) buildPlayer(username string, season metrics.Season) (*playerView, error) {
+	view := &playerView{Username: username, Season: season}
+
+	ranked, err := s.latest(username, "ranked", season, view)
+	if err != nil {
+		return nil, err
+	}
+	if ranked != nil {
+		view.Ranked = rankedFromPoints(ranked.Points, season.Slug)
+		since := time.Now().Add(-chartPeriod)
+		history, err := s.source.SnapshotHistory(username, "ranked", season.Slug, since)
+		if err != nil {
+			return nil, fmt.Errorf("could not load ranked history: %w", err)
+		}
+		view.Chart = mmrChart(history, season.Slug, since)
+	}
+
+	matches, err := s.latest(username, "matches", season, view)
+	if err != nil {
+		return nil, err
+	}
+	if matches != nil {
+		view.Matches = matchesFromPoints(matches.Points)
+	}
+
+	operators, err := s.latest(username, "operators", season, view)
+	if err != nil {
+		return nil, err
+	}
+	if operators != nil {
+		view.TopOperators = topRows(operators.Points, "actions", "operator", "rounds_played", "round_win_rate")
+	}
+
+	maps, err := s.latest(username, "maps", season, view)
+	if err != nil {
+		return nil, err
+	}
+	if maps != nil {
+		view.TopMaps = topRows(maps.Points, "maps", "map", "matches_played", "win_rate")
+	}
+	return view, nil
+}
+
+// buildSummary collects the data of a player shown on the index page, which are the ranked stats and the time of the latest update.
+func (s *Server) buildSummary(username string, season metrics.Season) (*playerView, error) {
+	view := &playerView{Username: username, Season: season}
+	ranked, err := s.latest(username, "ranked", season, view)
+	if err != nil {
+		return nil, err
+	}
+	if ranked != nil {
+		view.Ranked = rankedFromPoints(ranked.Points, season.Slug)
+	}
+	// matches are collected for every player, even without ranked stats
+	if _, err := s.latest(username, "matches", season, view); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// latest returns the latest snapshot of a collector and keeps track of the most recent update in view.
+func (s *Server) latest(username string, collector string, season metrics.Season, view *playerView) (*snapshot.Snapshot, error) {
+	snap, err := s.source.LatestSnapshot(username, collector, season.Slug)
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s snapshot: %w", collector, err)
+	}
+	if snap != nil && snap.LastSeenAt.After(view.UpdatedAt) {
+		view.UpdatedAt = snap.LastSeenAt
+	}
+	return snap, nil
+}
+
+// rankedPoint returns the ranked point of the given season, nil if the player has no ranked stats for it.
+// The snapshot also contains the points of past seasons, which must not be shown as current stats.
+func rankedPoint(points []snapshot.Point, seasonSlug string) *snapshot.Point {
+	for i, p := range points {
+		if p.Measurement == "ranked" && p.Tags["season_slug"] == seasonSlug {
+			return &points[i]
+		}
+	}
+	return nil
+}
+
+func rankedFromPoints(points []snapshot.Point, seasonSlug string) *rankedView {
+	p := rankedPoint(points, seasonSlug)
+	if p == nil {
+		return nil
+	}
+	return &rankedView{
+		MMR:     intField(p.Fields, "mmr"),
+		MaxMMR:  intField(p.Fields, "max_mmr"),
+		Rank:    rankName(p.Fields, "rank"),
+		MaxRank: rankName(p.Fields, "max_rank"),
+		Wins:    intField(p.Fields, "wins"),
+		Losses:  intField(p.Fields, "losses"),
+	}
+}
+
+// rankName returns the name of a numeric rank field, preferring the name added by enrichment.
+func rankName(fields map[string]interface{}, field string) string {
+	if name, ok := fields[field+"_name"].(string); ok {
+		return name
+	}
+	ordinal, ok := metrics.FloatField(fields, field)
+	if !ok {
+		return ""
+	}
+	rank, err := metrics.RankFromOrdinal(int(ordinal))
+	if err != nil {
+		return ""
+	}
+	return rank.String()
+}
+
+func matchesFromPoints(points []snapshot.Point) []matchesView {
+	var result []matchesView
+	for _, p := range points {
+		if p.Measurement != "matches" {
+			continue
+		}
+		result = append(result, matchesView{
+			Gamemode: p.Tags["gamemode"],
+			Played:   intField(p.Fields, "matches_played"),
+			Won:      intField(p.Fields, "matches_won"),
+			Lost:     intField(p.Fields, "matches_lost"),
+			WinRate:  floatField(p.Fields, "win_rate"),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Gamemode < result[j].Gamemode })
+	return result
+}
+
+// topRows returns the most played entries of a measurement for the overview game mode and role.
+func topRows(points []snapshot.Point, measurement string, nameTag string, playedField string, winRateField string) []statRow {
+	var rows []statRow
+	for _, p := range points {
+		if p.Measurement != measurement || p.Tags["gamemode"] != overviewGamemode {
+			continue
+		}
+		if role, ok := p.Tags["role"]; ok && role != overviewRole {
+			continue
+		}
+		rows = append(rows, statRow{
+			Name:    p.Tags[nameTag],
+			Played:  intField(p.Fields, playedField),
+			KD:      floatField(p.Fields, "kd_ratio"),
+			WinRate: floatField(p.Fields, winRateField),
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Played != rows[j].Played {
+			return rows[i].Played > rows[j].Played
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	if len(rows) > topCount {
+		rows = rows[:topCount]
+	}
+	return rows
+}
+
+func intField(fields map[string]interface{}, key string) int64 {
+	v, _ := metrics.FloatField(fields, key)
+	return int64(v)
+}
+
+func floatField(fields map[string]interface{}, key string) float64 {
+	v, _ := metrics.FloatField(fields, key)
+	return v
+}
+
+// Chart dimensions in SVG user units
+const (
+	chartWidth   = 600
+	chartHeight  = 160
+	chartPadding = 8
+)
+
+// chart is a line chart of the MMR over time, rendered as SVG polyline.
+type chart struct {
+	Width, Height int
+	// Points are the polyline coordinates
+	Points   string
+	Min, Max int64
+	From, To time.Time
+}
+
+// mmrChart returns the chart of the MMR in the given snapshots since the given time, nil if there are less than two values.
+func mmrChart(history []*snapshot.Snapshot, seasonSlug string, since time.Time) *chart {
+	type value struct {
+		t   time.Time
+		mmr int64
+	}
+	var values []value
+	for _, snap := range history {
+		p := rankedPoint(snap.Points, seasonSlug)
+		if p == nil {
+			continue
+		}
+		mmr := intField(p.Fields, "mmr")
+		takenAt := snap.TakenAt
+		if takenAt.Before(since) {
+			// the snapshot was still valid at the start of the chart
+			takenAt = since
+		}
+		values = append(values, value{takenAt, mmr})
+		// unchanged snapshots are stored once, so the value is valid until it was last seen
+		if snap.LastSeenAt.After(takenAt) {
+			values = append(values, value{snap.LastSeenAt, mmr})
+		}
+	}
+	if len(values) < 2 {
+		return nil
+	}
+
+	c := &chart{
+		Width:  chartWidth,
+		Height: chartHeight,
+		Min:    values[0].mmr,
+		Max:    values[0].mmr,
+		From:   values[0].t,
+		To:     values[len(values)-1].t,
+	}
+	for _, v := range values {
+		if v.mmr < c.Min {
+			c.Min = v.mmr
+		}
+		if v.mmr > c.Max {
+			c.Max = v.mmr
+		}
+	}
+	duration := c.To.Sub(c.From).Seconds()
+	mmrRange := float64(c.Max - c.Min)
+	coords := make([]string, len(values))
+	for i, v := range values {
+		x, y := 0.5, 0.5
+		if duration > 0 {
+			x = v.t.Sub(c.From).Seconds() / duration
+		}
+		if mmrRange > 0 {
+			y = float64(v.mmr-c.Min) / mmrRange
+		}
+		coords[i] = strconv.FormatFloat(chartPadding+x*(chartWidth-2*chartPadding), 'f', 1, 64) + "," +
+			strconv.FormatFloat(chartHeight-chartPadding-y*(chartHeight-2*chartPadding), 'f', 1, 64)
+	}
+	c.Points = strings.Join(coords, " ")
+	return c
+}
@@ -0,0 +1,138 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/snapshot"
+)
+
+func rankedTestPoint(season string, mmr int64) snapshot.Point {
+	return snapshot.Point{
+		Measurement: "ranked",
+		Tags:        map[string]string{"season_slug": season},
+		Fields:      map[string]interface{}{"mmr": float64(mmr)},
+	}
+}
+
+func TestRankedPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		points  []snapshot.Point
+		wantMMR int64
+		wantNil bool
+	}{
+		{"current season", []snapshot.Point{rankedTestPoint("Y8S3", 2500), rankedTestPoint("Y8S4", 3000)}, 3000, false},
+		{"only past seasons", []snapshot.Point{rankedTestPoint("Y8S3", 2500)}, 0, true},
+		{"other measurement", []snapshot.Point{{Measurement: "ranked_combined", Tags: map[string]string{"season_slug": "Y8S4"}}}, 0, true},
+		{"empty", nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := rankedPoint(tt.points, "Y8S4")
+			if tt.wantNil {
+				if p != nil {
+					t.Errorf("rankedPoint() = %+v, want nil", p)
+				}
+				return
+			}
+			if p == nil || intField(p.Fields, "mmr") != tt.wantMMR {
+				t.Errorf("rankedPoint() = %+v, want MMR %d", p, tt.wantMMR)
+			}
+		})
+	}
+}
+
+func TestMMRChart(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	history := []*snapshot.Snapshot{
+		// taken before the chart period, but still valid at its start
+		{TakenAt: since.Add(-time.Hour), LastSeenAt: since.Add(time.Hour), Points: []snapshot.Point{rankedTestPoint("Y8S4", 3000)}},
+		{TakenAt: since.Add(2 * time.Hour), LastSeenAt: since.Add(2 * time.Hour), Points: []snapshot.Point{rankedTestPoint("Y8S4", 3100)}},
+		{TakenAt: since.Add(3 * time.Hour), LastSeenAt: since.Add(3 * time.Hour), Points: []snapshot.Point{rankedTestPoint("Y8S3", 1000)}},
+	}
+
+	c := mmrChart(history, "Y8S4", since)
+	if c == nil {
+		t.Fatal("expected chart")
+	}
+	if !c.From.Equal(since) || !c.To.Equal(since.Add(2*time.Hour)) || c.Min != 3000 || c.Max != 3100 {
+		t.Errorf("chart = %+v, want range from %s to %s with 3000-3100 MMR", c, since, since.Add(2*time.Hour))
+	}
+	if got := len(strings.Fields(c.Points)); got != 3 {
+		t.Errorf("chart has %d points, want 3", got)
+	}
+
+	if c := mmrChart(history[2:], "Y8S4", since); c != nil {
+		t.Errorf("chart without values of the season = %+v, want nil", c)
+	}
+}
+
+// testSource is a DataSource with a single ranked snapshot per user, recording the history requests.
+type testSource struct {
+	ranked       map[string]*snapshot.Snapshot
+	historySince []time.Time
+}
+
+func (s *testSource) Leaderboard() *metrics.Leaderboard {
+	return nil
+}
+
+func (s *testSource) Usernames() []string {
+	return []string{"alice", "bob"}
+}
+
+func (s *testSource) CurrentSeason() (metrics.Season, bool) {
+	return metrics.Season{Slug: "Y8S4", Name: "Deep Freeze"}, true
+}
+
+func (s *testSource) LatestSnapshot(username string, collector string, _ string) (*snapshot.Snapshot, error) {
+	if collector != "ranked" {
+		return nil, nil
+	}
+	return s.ranked[username], nil
+}
+
+func (s *testSource) SnapshotHistory(username string, _ string, _ string, since time.Time) ([]*snapshot.Snapshot, error) {
+	s.historySince = append(s.historySince, since)
+	return []*snapshot.Snapshot{s.ranked[username]}, nil
+}
+
+func TestUIPages(t *testing.T) {
+	now := time.Now()
+	source := &testSource{ranked: map[string]*snapshot.Snapshot{
+		"alice": {TakenAt: now.Add(-time.Hour), LastSeenAt: now, Points: []snapshot.Point{rankedTestPoint("Y8S4", 3000)}},
+		"bob":   {TakenAt: now.Add(-time.Hour), LastSeenAt: now, Points: []snapshot.Point{rankedTestPoint("Y8S3", 2500)}},
+	}}
+	logger := zerolog.Nop()
+	handler := New("", source, true, &logger).httpServer.Handler
+
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s returned status %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	index := get("/")
+	if !strings.Contains(index, "3000") || strings.Contains(index, "2500") || !strings.Contains(index, "no ranked data") {
+		t.Errorf("index shows wrong ranked data:\n%s", index)
+	}
+	if len(source.historySince) != 0 {
+		t.Errorf("index loaded the ranked history %d times", len(source.historySince))
+	}
+
+	if page := get("/players/bob"); !strings.Contains(page, "No ranked data for this season.") || strings.Contains(page, "2500") {
+		t.Errorf("player page shows ranked data of a past season:\n%s", page)
+	}
+	if len(source.historySince) != 1 || source.historySince[0].Before(now.Add(-chartPeriod-time.Minute)) {
+		t.Errorf("ranked history loaded since %v, want bounded by the chart period", source.historySince)
+	}
+}
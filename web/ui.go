@@ -0,0 +1,131 @@
+package web
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stnokott/r6prom/constants"
+	"github.com/stnokott/r6prom/metrics"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+const playersPath = "/players/"
+
+var templateFuncs = template.FuncMap{
+	"percent": func(v float64) string {
+		return fmt.Sprintf("%.1f%%", v*100)
+	},
+	"ratio": func(v float64) string {
+		return fmt.Sprintf("%.2f", v)
+	},
+	"date": func(t time.Time) string {
+		return t.Local().Format("2006-01-02 15:04")
+	},
+	"playerURL": func(username string) string {
+		return playersPath + url.PathEscape(username)
+	},
+}
+
+var templates = template.Must(template.New("").Funcs(templateFuncs).ParseFS(uiFiles, "ui/templates/*.html"))
+
+// pageData is passed to all page templates.
+type pageData struct {
+	Name    string
+	Version string
+	Season  metrics.Season
+	// Players are shown on the index page, Player on player pages
+	Players []*playerView
+	Player  *playerView
+}
+
+// registerUI adds the web UI handlers to mux.
+func (s *Server) registerUI(mux *http.ServeMux) {
+	static, err := fs.Sub(uiFiles, "ui/static")
+	if err != nil {
+		panic(err)
+	}
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+	mux.HandleFunc(playersPath, s.handlePlayer)
+	mux.HandleFunc("/", s.handleIndex)
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	season, ok := s.uiSeason(w, r)
+	if !ok {
+		return
+	}
+	data := pageData{Season: season}
+	for _, username := range s.source.Usernames() {
+		player, err := s.buildSummary(username, season)
+		if err != nil {
+			s.uiError(w, err)
+			return
+		}
+		data.Players = append(data.Players, player)
+	}
+	s.render(w, "index.html", data)
+}
+
+func (s *Server) handlePlayer(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, playersPath)
+	username := ""
+	for _, observed := range s.source.Usernames() {
+		if strings.EqualFold(observed, name) {
+			username = observed
+			break
+		}
+	}
+	if username == "" {
+		http.NotFound(w, r)
+		return
+	}
+	season, ok := s.uiSeason(w, r)
+	if !ok {
+		return
+	}
+	player, err := s.buildPlayer(username, season)
+	if err != nil {
+		s.uiError(w, err)
+		return
+	}
+	s.render(w, "player.html", pageData{Season: season, Player: player})
+}
+
+// uiSeason returns the current season, ok is false if the request has been answered because it is not known yet.
+func (s *Server) uiSeason(w http.ResponseWriter, r *http.Request) (season metrics.Season, ok bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return metrics.Season{}, false
+	}
+	season, ok = s.source.CurrentSeason()
+	if !ok {
+		http.Error(w, "no stats collected yet", http.StatusServiceUnavailable)
+	}
+	return
+}
+
+func (s *Server) uiError(w http.ResponseWriter, err error) {
+	s.logger.Err(err).Msg("could not load web UI data")
+	http.Error(w, "could not load data", http.StatusInternalServerError)
+}
+
+func (s *Server) render(w http.ResponseWriter, name string, data pageData) {
+	data.Name = constants.NAME
+	data.Version = constants.VERSION
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		s.logger.Err(err).Str("template", name).Msg("could not render page")
+	}
+}
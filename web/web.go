@@ -0,0 +1,80 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stnokott/r6prom/metrics"
+	"github.com/stnokott/r6prom/snapshot"
+)
+
+// DataSource provides the data served by the web server.
+type DataSource interface {
+	Leaderboard() *metrics.Leaderboard
+	// Usernames returns the observed usernames.
+	Usernames() []string
+	// CurrentSeason returns the season of the latest run, ok is false if there was none yet.
+	CurrentSeason() (season metrics.Season, ok bool)
+	// LatestSnapshot returns the latest snapshot of a collector, nil if there is none.
+	LatestSnapshot(username string, collector string, seasonSlug string) (*snapshot.Snapshot, error)
+	// SnapshotHistory returns the snapshots of a collector taken since the given time, oldest first.
+	SnapshotHistory(username string, collector string, seasonSlug string, since time.Time) ([]*snapshot.Snapshot, error)
+}
+
+type Server struct {
+	httpServer *http.Server
+	source     DataSource
+	logger     *zerolog.Logger
+}
+
+// New creates a web server serving the leaderboard and, if ui is set, the web UI with player overviews.
+func New(addr string, source DataSource, ui bool, logger *zerolog.Logger) *Server {
+	s := &Server{
+		source: source,
+		logger: logger,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/leaderboard", s.handleLeaderboard)
+	if ui {
+		s.registerUI(mux)
+	}
+
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// RunAsync starts serving in the background.
+func (s *Server) RunAsync() {
+	go func() {
+		s.logger.Info().Str("addr", s.httpServer.Addr).Msg("starting web server")
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Err(err).Msg("web server stopped")
+		}
+	}()
+}
+
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lb := s.source.Leaderboard()
+	if lb == nil {
+		http.Error(w, "leaderboard not available yet", http.StatusServiceUnavailable)
+		return
+	}
+	s.writeJSON(w, lb)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Err(err).Msg("could not encode response")
+	}
+}